@@ -0,0 +1,107 @@
+package adapter
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	contentSecurityPolicyHeader = "Content-Security-Policy"
+	shopifyAdminOrigin          = "https://admin.shopify.com"
+)
+
+type hostContextKey struct{}
+
+// HostFromContext returns the host parameter validated by withEmbeddedHost,
+// still base64 encoded as App Bridge expects it.
+func HostFromContext(ctx context.Context) (string, bool) {
+	host, ok := ctx.Value(hostContextKey{}).(string)
+	return host, ok
+}
+
+// denyFraming forbids any page to embed the response, withFrameAncestors
+// relaxes it for the pages of the embedded app.
+func denyFraming(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentSecurityPolicyHeader, "frame-ancestors 'none'")
+		next(w, r)
+	}
+}
+
+// withFrameAncestors lets the admin of the shop of the session embed the
+// response, it must be used after withSessionToken.
+func withFrameAncestors(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFromContext(r.Context())
+		w.Header().Set(
+			contentSecurityPolicyHeader,
+			"frame-ancestors https://"+session.Shop()+" "+shopifyAdminOrigin,
+		)
+		next(w, r)
+	}
+}
+
+// withEmbeddedHost makes sure the page is loaded inside the admin of the shop
+// of its session token, which always gives the host parameter App Bridge
+// needs. Requests without it are redirected to the app in the admin of their
+// shop. The session token is authenticated in between, it must not be used
+// with withSessionToken.
+func (h *httpServer) withEmbeddedHost(next http.HandlerFunc) http.HandlerFunc {
+	withShopHost := h.withSessionToken(func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFromContext(r.Context())
+		host := r.URL.Query().Get("host")
+
+		// the host was decoded before the session token was authenticated
+		decoded, _ := shopify.DecodeHost(host)
+		if !isHostOfShop(decoded, session.Shop()) {
+			log.Ctx(r.Context()).Warn().Str("host", decoded).Msg("host parameter of another shop")
+			http.Error(w, `invalid "host" parameter`, http.StatusBadRequest)
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), hostContextKey{}, host)))
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		if query.Get("host") == "" {
+			shop := query.Get("shop")
+			if !shopify.IsValidShopDomain(shop) {
+				http.Error(w, `missing "host" parameter`, http.StatusBadRequest)
+				return
+			}
+
+			http.Redirect(w, r, h.adminAppUrl(shop), http.StatusSeeOther)
+			return
+		}
+
+		_, err := shopify.DecodeHost(query.Get("host"))
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Msg("invalid host parameter")
+			http.Error(w, `invalid "host" parameter`, http.StatusBadRequest)
+			return
+		}
+
+		withShopHost(w, r)
+	}
+}
+
+// isHostOfShop reports whether the decoded host parameter names the admin of
+// shop, either "admin.shopify.com/store/{handle}" or "{shop}/admin".
+func isHostOfShop(host string, shop string) bool {
+	if handle, found := strings.CutPrefix(host, "admin.shopify.com/store/"); found {
+		return strings.EqualFold(handle+".myshopify.com", shop)
+	}
+
+	return strings.EqualFold(host, shop+"/admin")
+}
+
+// adminAppUrl returns the url of the app embedded in the admin of shop.
+func (h *httpServer) adminAppUrl(shop string) string {
+	return "https://" + shop + "/admin/apps/" + h.apiKey
+}
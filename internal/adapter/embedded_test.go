@@ -0,0 +1,149 @@
+package adapter
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/usecase"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify/shopifytest"
+)
+
+const testAPIKey = "api-key"
+
+// installedUsecase authorizes every session token, as for an installed shop.
+type installedUsecase struct {
+	usecase.ShopifyUsecase
+}
+
+func (installedUsecase) AuthorizeSession(ctx context.Context, session *shopify.SessionToken) error {
+	return nil
+}
+
+func TestDenyFraming(t *testing.T) {
+	w := httptest.NewRecorder()
+	denyFraming(func(w http.ResponseWriter, r *http.Request) {})(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if got := w.Header().Get(contentSecurityPolicyHeader); got != "frame-ancestors 'none'" {
+		t.Errorf("Content-Security-Policy = %q, want frame-ancestors 'none'", got)
+	}
+}
+
+func TestWithFrameAncestors(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+
+	for _, shop := range []string{"one.myshopify.com", "two.myshopify.com"} {
+		t.Run(shop, func(t *testing.T) {
+			server.Shop = shop
+			session, err := shopify.ParseSessionToken(server.SessionToken(1), testAPIKey, testAPISecret, 0, time.Now())
+			if err != nil {
+				t.Fatalf("ParseSessionToken() error = %v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "/app", nil)
+			r = r.WithContext(context.WithValue(r.Context(), sessionContextKey{}, session))
+
+			// the pages of the app are embedded despite denyFraming
+			w := httptest.NewRecorder()
+			denyFraming(withFrameAncestors(func(w http.ResponseWriter, r *http.Request) {}))(w, r)
+
+			want := "frame-ancestors https://" + shop + " https://admin.shopify.com"
+			if got := w.Header().Get(contentSecurityPolicyHeader); got != want {
+				t.Errorf("Content-Security-Policy = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestWithEmbeddedHost(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+
+	handle, _, _ := strings.Cut(server.Shop, ".")
+	encode := func(host string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(host))
+	}
+
+	tests := []struct {
+		name     string
+		query    url.Values
+		want     int
+		location string
+	}{
+		{
+			name:  "unified admin",
+			query: url.Values{"host": {encode("admin.shopify.com/store/" + handle)}},
+			want:  http.StatusOK,
+		},
+		{
+			name:  "legacy admin",
+			query: url.Values{"host": {encode(server.Shop + "/admin")}},
+			want:  http.StatusOK,
+		},
+		{
+			name:     "missing host",
+			query:    url.Values{"shop": {server.Shop}},
+			want:     http.StatusSeeOther,
+			location: "https://" + server.Shop + "/admin/apps/" + testAPIKey,
+		},
+		{
+			name:  "missing host and shop",
+			query: url.Values{},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "not base64",
+			query: url.Values{"host": {"%%%"}},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "not an admin",
+			query: url.Values{"host": {encode("evil.example.com")}},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "admin of another shop",
+			query: url.Values{"host": {encode("admin.shopify.com/store/other")}},
+			want:  http.StatusBadRequest,
+		},
+		{
+			name:  "legacy admin of another shop",
+			query: url.Values{"host": {encode("other.myshopify.com/admin")}},
+			want:  http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &httpServer{apiKey: testAPIKey, apiSecret: testAPISecret, usecase: installedUsecase{}}
+
+			var host string
+			handler := h.withEmbeddedHost(func(w http.ResponseWriter, r *http.Request) {
+				host, _ = HostFromContext(r.Context())
+			})
+
+			r := httptest.NewRequest(http.MethodGet, "/app?"+tt.query.Encode(), nil)
+			r.Header.Set("Authorization", "Bearer "+server.SessionToken(1))
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			// App Bridge is given the host as sent by Shopify
+			if tt.want == http.StatusOK && host != tt.query.Get("host") {
+				t.Errorf("HostFromContext() = %q, want %q", host, tt.query.Get("host"))
+			}
+		})
+	}
+}
@@ -1,8 +1,14 @@
 package adapter
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
 	"net/http"
-	"text/template"
 
 	"github.com/rs/zerolog/log"
 	"github.com/zeals-co-ltd/shopify-app-example/internal/config"
@@ -11,7 +17,11 @@ import (
 )
 
 const (
-	scopes = "read_products,write_products"
+	scopes          = "read_products,write_products"
+	requestIdHeader = "X-Request-Id"
+	// maxWebhookBodySize bounds the body read before its HMAC is verified,
+	// well above the payload of a product with every variant.
+	maxWebhookBodySize = 5 << 20
 )
 
 type HttpServer interface {
@@ -19,17 +29,19 @@ type HttpServer interface {
 }
 
 type httpServer struct {
-	shopifyClient shopify.Client
-	scopes        string
-	apiKey        string
-	apiSecret     string
-	serverUrl     string
-	usecase       usecase.ShopifyUsecase
+	shopifyClient     shopify.Client
+	scopes            string
+	apiKey            string
+	apiSecret         string
+	serverUrl         string
+	usecase           usecase.ShopifyUsecase
+	complianceUsecase usecase.ComplianceUsecase
 }
 
 func NewHttpServer(
 	shopifyClient shopify.Client,
 	shopifyUsecase usecase.ShopifyUsecase,
+	complianceUsecase usecase.ComplianceUsecase,
 ) (HttpServer, error) {
 	apiSecret, err := config.MustGet("SHOPIFY_CLIENT_SECRET")
 	if err != nil {
@@ -47,20 +59,31 @@ func NewHttpServer(
 		return nil, err
 	}
 	return &httpServer{
-		shopifyClient: shopifyClient,
-		scopes:        scopes,
-		apiKey:        apiKey,
-		apiSecret:     apiSecret,
-		serverUrl:     serverUrl,
-		usecase:       shopifyUsecase,
+		shopifyClient:     shopifyClient,
+		scopes:            scopes,
+		apiKey:            apiKey,
+		apiSecret:         apiSecret,
+		serverUrl:         serverUrl,
+		usecase:           shopifyUsecase,
+		complianceUsecase: complianceUsecase,
 	}, nil
 }
 
 func (h *httpServer) Run(port string) error {
-	http.HandleFunc("/shopify", h.shopifyHandler())
-	http.HandleFunc("/shopify/callback", h.shopifyCallbackHandler())
-	http.HandleFunc("/app", h.appHandler())
-	http.HandleFunc("/webhook", h.webhookHandler())
+	handle := func(pattern string, handler http.HandlerFunc) {
+		http.HandleFunc(pattern, withRequestLogger(denyFraming(handler)))
+	}
+
+	handle("/shopify", h.shopifyHandler())
+	handle("/shopify/callback", h.shopifyCallbackHandler())
+	handle("/app", h.withEmbeddedHost(withFrameAncestors(h.appHandler())))
+	handle("/api/session", h.withSessionToken(h.withUserSession(h.sessionHandler())))
+	handle("/api/products/backfill", h.withSessionToken(h.productBackfillHandler()))
+	handle("/api/products/export", h.withSessionToken(h.productExportHandler()))
+	handle("/webhook", h.webhookHandler(h.usecase.HandleWebhook))
+	handle("/webhook/customers/data_request", h.webhookHandler(h.complianceUsecase.HandleCustomersDataRequest))
+	handle("/webhook/customers/redact", h.webhookHandler(h.complianceUsecase.HandleCustomersRedact))
+	handle("/webhook/shop/redact", h.webhookHandler(h.complianceUsecase.HandleShopRedact))
 
 	return http.ListenAndServe(port, nil)
 }
@@ -93,8 +116,9 @@ func (h *httpServer) shopifyCallbackHandler() http.HandlerFunc {
 			return
 		}
 
-		// TODO: pass session
-		http.Redirect(w, r, "/app", http.StatusSeeOther)
+		// the app is opened inside the admin, where App Bridge provides the
+		// session tokens
+		http.Redirect(w, r, h.adminAppUrl(r.URL.Query().Get("shop")), http.StatusSeeOther)
 	}
 }
 
@@ -106,9 +130,14 @@ func (h *httpServer) appHandler() http.HandlerFunc {
 			return
 		}
 
+		session, _ := SessionFromContext(r.Context())
+		host, _ := HostFromContext(r.Context())
+
 		var data = map[string]interface{}{
-			"title": "Shopify app testing",
-			"name":  "Batman",
+			"title":  "Shopify app testing",
+			"name":   session.Shop(),
+			"apiKey": h.apiKey,
+			"host":   host,
 		}
 
 		err = tmpl.Execute(w, data)
@@ -118,9 +147,167 @@ func (h *httpServer) appHandler() http.HandlerFunc {
 	}
 }
 
-func (h *httpServer) webhookHandler() http.HandlerFunc {
+// sessionHandler returns the shop and the staff member of the session, e.g.
+// for the frontend to check its authentication.
+func (h *httpServer) sessionHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, _ := UserSessionFromContext(r.Context())
+
+		response := SessionResponse{
+			Shop:      session.Shop,
+			UserID:    session.UserID,
+			Email:     session.AssociatedUser.Email,
+			FirstName: session.AssociatedUser.FirstName,
+			LastName:  session.AssociatedUser.LastName,
+			ExpiresAt: session.ExpiresAt,
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(response.ToJson())
+	}
+}
+
+// productBackfillHandler returns the progress of the copy of the products
+// of the shop made after its install.
+func (h *httpServer) productBackfillHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFromContext(r.Context())
+
+		backfill, err := h.usecase.GetProductBackfill(r.Context(), session.Shop())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if backfill.IsEmpty() {
+			response := ErrorResponse{Errors: "no product backfill"}
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(response.ToJson())
+			return
+		}
+
+		response := ProductBackfillResponse{
+			Status:       backfill.Status,
+			ProductCount: backfill.ProductCount,
+			SyncedCount:  backfill.SyncedCount,
+			Error:        backfill.Error,
+			StartedAt:    backfill.StartedAt,
+			FinishedAt:   backfill.FinishedAt,
+		}
+		w.Write(response.ToJson())
+	}
+}
+
+// productExportHandler streams every product of the shop of the session token
+// as JSON lines, from a bulk operation which can take minutes for large
+// catalogs.
+func (h *httpServer) productExportHandler() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		// TODO: call usecase
+		session, _ := SessionFromContext(r.Context())
+		flusher, _ := w.(http.Flusher)
+		encoder := json.NewEncoder(w)
+		exported := 0
+
+		// replaced by http.Error when the export fails before any product
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		err := h.usecase.ExportCatalog(r.Context(), usecase.ExportCatalogRequest{
+			Shop: session.Shop(),
+			Handler: func(ctx context.Context, product shopify.Product) error {
+				exported++
+
+				if err := encoder.Encode(product); err != nil {
+					return err
+				}
+				if flusher != nil {
+					flusher.Flush()
+				}
+				return nil
+			},
+		})
+		if err != nil && exported == 0 {
+			log.Ctx(r.Context()).Error().Err(err).Msg("failed to export products")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			// the status was sent with the first product, the truncated
+			// response is the only sign of the failure
+			log.Ctx(r.Context()).Error().Err(err).Int("exported", exported).Msg("product export interrupted")
+		}
+	}
+}
+
+// withRequestLogger attaches a logger with the id of the request to its
+// context, the id sent by the caller in X-Request-Id is reused if any. The
+// context is cancelled when the client goes away, which also cancels the
+// calls to Shopify made on its behalf.
+func withRequestLogger(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get(requestIdHeader)
+		if requestId == "" {
+			requestId = newRequestId()
+		}
+		w.Header().Set(requestIdHeader, requestId)
+
+		logger := log.With().Str("request_id", requestId).Logger()
+		next(w, r.WithContext(logger.WithContext(r.Context())))
+	}
+}
+
+func newRequestId() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// webhookHandler verifies the HMAC of the webhook before passing it to
+// handle, unverified requests are rejected with 401. Invalid webhooks are
+// rejected with 400 and the other errors with 500.
+func (h *httpServer) webhookHandler(
+	handle func(ctx context.Context, req usecase.WebhookRequest) error,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodySize))
+		if err != nil {
+			var maxBytesErr *http.MaxBytesError
+			if errors.As(err, &maxBytesErr) {
+				w.WriteHeader(http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		ok, err := shopify.VerifyWebhook(body, r.Header.Get(shopify.WebhookHmacHeader), h.apiSecret)
+		if !ok || err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Msg("invalid webhook hmac")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		err = handle(r.Context(), usecase.WebhookRequest{
+			Header: r.Header,
+			Body:   body,
+		})
+		if err != nil {
+			// only a malformed webhook is the fault of the sender, the delivery
+			// metrics of Shopify tell both apart
+			statusCode := http.StatusInternalServerError
+			if errors.Is(err, usecase.ErrInvalidWebhook) {
+				statusCode = http.StatusBadRequest
+			}
+
+			response := ErrorResponse{Errors: err.Error()}
+			w.WriteHeader(statusCode)
+			w.Write(response.ToJson())
+			return
+		}
 
 		w.WriteHeader(http.StatusOK)
 	}
@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/usecase"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify/shopifytest"
+)
+
+const testAPISecret = "api-secret"
+
+func signWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(testAPISecret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookHandler(t *testing.T) {
+	body := []byte(`{"id":1}`)
+
+	tests := []struct {
+		name      string
+		method    string
+		body      []byte
+		signature string
+		err       error
+		want      int
+	}{
+		{"handled", http.MethodPost, body, signWebhook(body), nil, http.StatusOK},
+		{"not a POST", http.MethodGet, nil, "", nil, http.StatusMethodNotAllowed},
+		{"invalid signature", http.MethodPost, body, signWebhook([]byte("other")), nil, http.StatusUnauthorized},
+		{"too large", http.MethodPost, make([]byte, maxWebhookBodySize+1), "", nil, http.StatusRequestEntityTooLarge},
+		{"invalid webhook", http.MethodPost, body, signWebhook(body), fmt.Errorf("%w: missing header", usecase.ErrInvalidWebhook), http.StatusBadRequest},
+		{"failed", http.MethodPost, body, signWebhook(body), errors.New("database is down"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &httpServer{apiSecret: testAPISecret}
+			called := false
+			handler := h.webhookHandler(func(ctx context.Context, req usecase.WebhookRequest) error {
+				called = true
+				if !bytes.Equal(req.Body, tt.body) {
+					t.Errorf("handle() body = %s, want %s", req.Body, tt.body)
+				}
+				return tt.err
+			})
+
+			r := httptest.NewRequest(tt.method, "/webhook", bytes.NewReader(tt.body))
+			r.Header.Set(shopify.WebhookHmacHeader, tt.signature)
+			w := httptest.NewRecorder()
+			handler(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+
+			verified := tt.want == http.StatusOK || tt.err != nil
+			if called != verified {
+				t.Errorf("handle() called = %v, want %v", called, verified)
+			}
+		})
+	}
+}
+
+// exportUsecase exports products then fails with err.
+type exportUsecase struct {
+	installedUsecase
+	products []shopify.Product
+	err      error
+}
+
+func (u exportUsecase) ExportCatalog(ctx context.Context, req usecase.ExportCatalogRequest) error {
+	for _, product := range u.products {
+		if err := req.Handler(ctx, product); err != nil {
+			return err
+		}
+	}
+	return u.err
+}
+
+func TestProductExportHandler(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+
+	products := []shopify.Product{{ID: 1, Title: "one"}, {ID: 2, Title: "two"}}
+
+	tests := []struct {
+		name        string
+		usecase     exportUsecase
+		want        int
+		contentType string
+		lines       int
+	}{
+		{"exported", exportUsecase{products: products}, http.StatusOK, "application/x-ndjson", 2},
+		{"failed", exportUsecase{err: errors.New("bulk operation failed")}, http.StatusInternalServerError, "text/plain; charset=utf-8", 1},
+		{"interrupted", exportUsecase{products: products[:1], err: errors.New("connection reset")}, http.StatusOK, "application/x-ndjson", 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &httpServer{apiKey: testAPIKey, apiSecret: testAPISecret, usecase: tt.usecase}
+
+			r := httptest.NewRequest(http.MethodGet, "/api/products/export", nil)
+			r.Header.Set("Authorization", "Bearer "+server.SessionToken(1))
+			w := httptest.NewRecorder()
+			h.withSessionToken(h.productExportHandler())(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if lines := strings.Count(w.Body.String(), "\n"); lines != tt.lines {
+				t.Errorf("body = %q, want %d lines", w.Body.String(), tt.lines)
+			}
+		})
+	}
+}
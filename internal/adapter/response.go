@@ -1,6 +1,9 @@
 package adapter
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"time"
+)
 
 type ErrorResponse struct {
 	Errors string `json:"errors"`
@@ -10,3 +13,31 @@ func (e *ErrorResponse) ToJson() []byte {
 	result, _ := json.Marshal(e)
 	return result
 }
+
+type SessionResponse struct {
+	Shop      string     `json:"shop"`
+	UserID    int64      `json:"user_id"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+func (s *SessionResponse) ToJson() []byte {
+	result, _ := json.Marshal(s)
+	return result
+}
+
+type ProductBackfillResponse struct {
+	Status       string     `json:"status"`
+	ProductCount int        `json:"product_count"`
+	SyncedCount  int        `json:"synced_count"`
+	Error        string     `json:"error,omitempty"`
+	StartedAt    *time.Time `json:"started_at"`
+	FinishedAt   *time.Time `json:"finished_at"`
+}
+
+func (b *ProductBackfillResponse) ToJson() []byte {
+	result, _ := json.Marshal(b)
+	return result
+}
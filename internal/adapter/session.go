@@ -0,0 +1,136 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/usecase"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	sessionTokenLeeway = 10 * time.Second
+	// retryInvalidSessionHeader asks App Bridge to fetch a new session token
+	// and retry the request.
+	retryInvalidSessionHeader = "X-Shopify-Retry-Invalid-Session-Request"
+)
+
+var errUnauthenticated = errors.New("unauthenticated")
+
+type sessionContextKey struct{}
+
+type userSessionContextKey struct{}
+
+// SessionFromContext returns the session token validated by
+// withSessionToken.
+func SessionFromContext(ctx context.Context) (*shopify.SessionToken, bool) {
+	session, ok := ctx.Value(sessionContextKey{}).(*shopify.SessionToken)
+	return session, ok
+}
+
+// UserSessionFromContext returns the online session set by withUserSession.
+func UserSessionFromContext(ctx context.Context) (model.ShopifySession, bool) {
+	session, ok := ctx.Value(userSessionContextKey{}).(model.ShopifySession)
+	return session, ok
+}
+
+// withSessionToken only lets through the requests carrying a valid App
+// Bridge session token, the shop is installed on its first request. The token
+// is read from the Authorization header, or from the id_token parameter that
+// Shopify adds when loading the app in the admin.
+func (h *httpServer) withSessionToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, err := h.authenticate(r)
+		if err != nil && !errors.Is(err, errUnauthenticated) {
+			log.Ctx(r.Context()).Error().Err(err).Msg("failed to authenticate session token")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Msg("invalid session token")
+
+			response := ErrorResponse{Errors: "invalid session token"}
+			w.Header().Set(retryInvalidSessionHeader, "1")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(response.ToJson())
+			return
+		}
+
+		logger := log.Ctx(r.Context()).With().
+			Str("shop", session.Shop()).
+			Str("user_id", session.UserID()).
+			Logger()
+		ctx := context.WithValue(logger.WithContext(r.Context()), sessionContextKey{}, session)
+
+		next(w, r.WithContext(ctx))
+	}
+}
+
+func (h *httpServer) authenticate(r *http.Request) (*shopify.SessionToken, error) {
+	token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !found {
+		token = r.URL.Query().Get("id_token")
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("%w: session token is missing", errUnauthenticated)
+	}
+
+	session, err := shopify.ParseSessionToken(token, h.apiKey, h.apiSecret, sessionTokenLeeway, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", errUnauthenticated, err)
+	}
+
+	err = h.usecase.AuthorizeSession(r.Context(), session)
+	if errors.Is(err, usecase.ErrUnauthorized) {
+		return nil, fmt.Errorf("%w: %w", errUnauthenticated, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// withUserSession provides the online access token of the user to the routes
+// acting on behalf of a staff member, it must be used after withSessionToken.
+// Documents loaded without App Bridge are redirected to the online OAuth
+// when the token cannot be renewed.
+func (h *httpServer) withUserSession(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		session, _ := SessionFromContext(r.Context())
+
+		userSession, err := h.usecase.AuthorizeUser(r.Context(), session)
+		if err != nil && !errors.Is(err, usecase.ErrUnauthorized) {
+			log.Ctx(r.Context()).Error().Err(err).Msg("failed to authorize user")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		if err != nil {
+			log.Ctx(r.Context()).Warn().Err(err).Msg("failed to authorize user")
+
+			if r.Header.Get("Authorization") == "" {
+				query := url.Values{}
+				query.Set("shop", session.Shop())
+				query.Set("access_mode", "online")
+				http.Redirect(w, r, "/shopify?"+query.Encode(), http.StatusSeeOther)
+				return
+			}
+
+			response := ErrorResponse{Errors: "invalid session token"}
+			w.Header().Set(retryInvalidSessionHeader, "1")
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write(response.ToJson())
+			return
+		}
+
+		next(w, r.WithContext(context.WithValue(r.Context(), userSessionContextKey{}, userSession)))
+	}
+}
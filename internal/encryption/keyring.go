@@ -0,0 +1,196 @@
+package encryption
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	keySize = 32
+)
+
+// Envelope is a value encrypted with a random data key, the data key itself
+// being encrypted with the key encryption key KeyID of the Keyring. Rotating
+// the key encryption key only requires re-encrypting DataKey.
+type Envelope struct {
+	KeyID      string
+	DataKey    string
+	Ciphertext string
+}
+
+// Keyring holds the key encryption keys by ID. New values are always
+// encrypted with the primary key, the other keys are kept to decrypt values
+// that were not rotated yet.
+type Keyring struct {
+	primaryID string
+	keys      map[string][]byte
+}
+
+func NewKeyring(primaryID string, keys map[string][]byte) (*Keyring, error) {
+	if _, ok := keys[primaryID]; !ok {
+		return nil, fmt.Errorf("primary key %q is not in the keyring", primaryID)
+	}
+
+	for id, key := range keys {
+		if len(key) != keySize {
+			return nil, fmt.Errorf("key %q must be %d bytes long", id, keySize)
+		}
+	}
+
+	return &Keyring{
+		primaryID: primaryID,
+		keys:      keys,
+	}, nil
+}
+
+// NewEphemeralKeyring returns a keyring with a random key, the values it
+// encrypts cannot be decrypted once it is lost, e.g. by a restart.
+func NewEphemeralKeyring() (*Keyring, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+
+	return NewKeyring("ephemeral", map[string][]byte{"ephemeral": key})
+}
+
+// ParseKeyring reads keys formatted as "id1:base64key1,id2:base64key2".
+func ParseKeyring(primaryID string, keys string) (*Keyring, error) {
+	parsed := map[string][]byte{}
+	for _, pair := range strings.Split(keys, ",") {
+		id, encoded, found := strings.Cut(strings.TrimSpace(pair), ":")
+		if !found || id == "" {
+			return nil, errors.New(`keys must be formatted as "id:base64key"`)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode key %q: %w", id, err)
+		}
+		parsed[id] = key
+	}
+
+	return NewKeyring(primaryID, parsed)
+}
+
+func (k *Keyring) PrimaryKeyID() string {
+	return k.primaryID
+}
+
+// Encrypt seals plaintext in a new envelope. additionalData is authenticated
+// but not encrypted, it must be given again to Decrypt.
+func (k *Keyring) Encrypt(plaintext []byte, additionalData []byte) (Envelope, error) {
+	dataKey := make([]byte, keySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return Envelope{}, err
+	}
+
+	ciphertext, err := seal(dataKey, plaintext, additionalData)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	wrappedKey, err := seal(k.keys[k.primaryID], dataKey, nil)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		KeyID:      k.primaryID,
+		DataKey:    base64.StdEncoding.EncodeToString(wrappedKey),
+		Ciphertext: base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+func (k *Keyring) Decrypt(envelope Envelope, additionalData []byte) ([]byte, error) {
+	dataKey, err := k.unwrap(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(envelope.Ciphertext)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(dataKey, ciphertext, additionalData)
+}
+
+// Rewrap encrypts the data key of envelope with the primary key, the
+// ciphertext is left untouched.
+func (k *Keyring) Rewrap(envelope Envelope) (Envelope, error) {
+	dataKey, err := k.unwrap(envelope)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	wrappedKey, err := seal(k.keys[k.primaryID], dataKey, nil)
+	if err != nil {
+		return Envelope{}, err
+	}
+
+	return Envelope{
+		KeyID:      k.primaryID,
+		DataKey:    base64.StdEncoding.EncodeToString(wrappedKey),
+		Ciphertext: envelope.Ciphertext,
+	}, nil
+}
+
+func (k *Keyring) unwrap(envelope Envelope) ([]byte, error) {
+	key, ok := k.keys[envelope.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown key %q", envelope.KeyID)
+	}
+
+	wrappedKey, err := base64.StdEncoding.DecodeString(envelope.DataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return open(key, wrappedKey, nil)
+}
+
+// seal encrypts plaintext with AES-GCM, the random nonce is prepended to the
+// returned ciphertext.
+func seal(key []byte, plaintext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func open(key []byte, ciphertext []byte, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < aead.NonceSize() {
+		return nil, errors.New("ciphertext is too short")
+	}
+
+	nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+	return aead.Open(nil, nonce, sealed, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,150 @@
+package encryption
+
+import (
+	"bytes"
+	"testing"
+)
+
+func newTestKeyring(t *testing.T, primaryID string, keys map[string][]byte) *Keyring {
+	t.Helper()
+
+	keyring, err := NewKeyring(primaryID, keys)
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	return keyring
+}
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, keySize)
+}
+
+func TestKeyringEncryptDecrypt(t *testing.T) {
+	keyring := newTestKeyring(t, "key1", map[string][]byte{"key1": testKey(1)})
+
+	plaintext := []byte("shpat_secret")
+	envelope, err := keyring.Encrypt(plaintext, []byte("shop.myshopify.com"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	if envelope.KeyID != "key1" {
+		t.Errorf("Encrypt() key id = %q, want key1", envelope.KeyID)
+	}
+	if bytes.Contains([]byte(envelope.Ciphertext), plaintext) {
+		t.Error("Encrypt() ciphertext contains the plaintext")
+	}
+
+	decrypted, err := keyring.Decrypt(envelope, []byte("shop.myshopify.com"))
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypt() = %q, want %q", decrypted, plaintext)
+	}
+
+	// the envelope of a shop cannot be moved to another one
+	if _, err := keyring.Decrypt(envelope, []byte("other.myshopify.com")); err == nil {
+		t.Error("Decrypt() succeeded with another additional data")
+	}
+}
+
+func TestKeyringDecryptUnknownKey(t *testing.T) {
+	keyring := newTestKeyring(t, "key1", map[string][]byte{"key1": testKey(1)})
+	envelope, err := keyring.Encrypt([]byte("secret"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	other := newTestKeyring(t, "key2", map[string][]byte{"key2": testKey(2)})
+	if _, err := other.Decrypt(envelope, nil); err == nil {
+		t.Error("Decrypt() succeeded without the key of the envelope")
+	}
+
+	// same id, different key
+	forged := newTestKeyring(t, "key1", map[string][]byte{"key1": testKey(3)})
+	if _, err := forged.Decrypt(envelope, nil); err == nil {
+		t.Error("Decrypt() succeeded with another key of the same id")
+	}
+}
+
+func TestKeyringRewrap(t *testing.T) {
+	old := newTestKeyring(t, "key1", map[string][]byte{"key1": testKey(1)})
+	envelope, err := old.Encrypt([]byte("secret"), []byte("aad"))
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+
+	rotated := newTestKeyring(t, "key2", map[string][]byte{"key1": testKey(1), "key2": testKey(2)})
+	rewrapped, err := rotated.Rewrap(envelope)
+	if err != nil {
+		t.Fatalf("Rewrap() error = %v", err)
+	}
+
+	if rewrapped.KeyID != "key2" {
+		t.Errorf("Rewrap() key id = %q, want key2", rewrapped.KeyID)
+	}
+	if rewrapped.Ciphertext != envelope.Ciphertext {
+		t.Error("Rewrap() changed the ciphertext")
+	}
+
+	// the old key can be removed once every envelope is rewrapped
+	current := newTestKeyring(t, "key2", map[string][]byte{"key2": testKey(2)})
+	decrypted, err := current.Decrypt(rewrapped, []byte("aad"))
+	if err != nil {
+		t.Fatalf("Decrypt() error = %v", err)
+	}
+	if string(decrypted) != "secret" {
+		t.Errorf("Decrypt() = %q, want secret", decrypted)
+	}
+}
+
+func TestParseKeyring(t *testing.T) {
+	tests := []struct {
+		name      string
+		primaryID string
+		keys      string
+		wantErr   bool
+	}{
+		{"valid", "key1", "key1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", false},
+		{"several keys", "key2", "key1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=, key2:AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=", false},
+		{"missing primary", "key2", "key1:AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", true},
+		{"short key", "key1", "key1:AQEB", true},
+		{"invalid base64", "key1", "key1:not base64", true},
+		{"missing id", "key1", "AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeyring(tt.primaryID, tt.keys)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseKeyring() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewEphemeralKeyring(t *testing.T) {
+	keyring, err := NewEphemeralKeyring()
+	if err != nil {
+		t.Fatalf("NewEphemeralKeyring() error = %v", err)
+	}
+
+	envelope, err := keyring.Encrypt([]byte("shpat_secret"), nil)
+	if err != nil {
+		t.Fatalf("Encrypt() error = %v", err)
+	}
+	if decrypted, err := keyring.Decrypt(envelope, nil); err != nil || string(decrypted) != "shpat_secret" {
+		t.Errorf("Decrypt() = %q, %v, want shpat_secret", decrypted, err)
+	}
+
+	// every keyring has its own key
+	other, err := NewEphemeralKeyring()
+	if err != nil {
+		t.Fatalf("NewEphemeralKeyring() error = %v", err)
+	}
+	if _, err := other.Decrypt(envelope, nil); err == nil {
+		t.Error("Decrypt() succeeded with another ephemeral keyring")
+	}
+}
@@ -0,0 +1,55 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ComplianceStatusReceived  = "received"
+	ComplianceStatusCompleted = "completed"
+	ComplianceStatusFailed    = "failed"
+)
+
+// ComplianceRequest records a privacy webhook received from Shopify and how
+// it was handled, for auditing.
+type ComplianceRequest struct {
+	ID          primitive.ObjectID  `bson:"_id"`
+	Shop        string              `bson:"shop"`
+	Topic       string              `bson:"topic"`
+	WebhookID   string              `bson:"webhook_id"`
+	CustomerID  int64               `bson:"customer_id,omitempty"`
+	Status      string              `bson:"status"`
+	Error       string              `bson:"error,omitempty"`
+	Export      *CustomerDataExport `bson:"export,omitempty"`
+	CompletedAt *time.Time          `bson:"completed_at,omitempty"`
+	CreatedAt   *time.Time          `bson:"created_at,omitempty"`
+	UpdatedAt   *time.Time          `bson:"updated_at,omitempty"`
+}
+
+// CustomerDataExport is the bundle of the data stored about a customer, to
+// be sent to the merchant for a customers/data_request. The customer is only
+// identified by id, the bundle is kept along with the audit record.
+type CustomerDataExport struct {
+	Shop            string                 `bson:"shop" json:"shop"`
+	CustomerID      int64                  `bson:"customer_id" json:"customer_id"`
+	OrdersRequested []int64                `bson:"orders_requested" json:"orders_requested"`
+	Data            map[string]interface{} `bson:"data" json:"data"`
+	GeneratedAt     *time.Time             `bson:"generated_at" json:"generated_at"`
+}
+
+func (c *ComplianceRequest) SetID() {
+	if c.ID.IsZero() {
+		c.ID = primitive.NewObjectID()
+	}
+}
+
+func (c *ComplianceRequest) UpdateDate() {
+	now := time.Now()
+	if c.CreatedAt == nil {
+		c.CreatedAt = &now
+	}
+
+	c.UpdatedAt = &now
+}
@@ -0,0 +1,42 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OauthNonce is the state sent to Shopify when requesting an authorization,
+// it must come back unchanged in the callback.
+type OauthNonce struct {
+	ID        primitive.ObjectID `bson:"_id"`
+	Shop      string             `bson:"shop"`
+	Nonce     string             `bson:"nonce"`
+	ExpiresAt *time.Time         `bson:"expires_at,omitempty"`
+	CreatedAt *time.Time         `bson:"created_at,omitempty"`
+}
+
+func (n OauthNonce) IsEmpty() bool {
+	return n.ID.IsZero() &&
+		n.Shop == "" &&
+		n.Nonce == "" &&
+		n.ExpiresAt == nil &&
+		n.CreatedAt == nil
+}
+
+func (n OauthNonce) IsExpired() bool {
+	return n.ExpiresAt != nil && n.ExpiresAt.Before(time.Now())
+}
+
+func (n *OauthNonce) SetID() {
+	if n.ID.IsZero() {
+		n.ID = primitive.NewObjectID()
+	}
+}
+
+func (n *OauthNonce) UpdateDate() {
+	now := time.Now()
+	if n.CreatedAt == nil {
+		n.CreatedAt = &now
+	}
+}
@@ -0,0 +1,76 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Product mirrors a product of a shop as last received from Shopify.
+type Product struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Shop        string             `bson:"shop"`
+	ProductID   int64              `bson:"product_id"`
+	Title       string             `bson:"title"`
+	BodyHTML    string             `bson:"body_html"`
+	Vendor      string             `bson:"vendor"`
+	ProductType string             `bson:"product_type"`
+	Handle      string             `bson:"handle"`
+	Status      string             `bson:"status"`
+	Tags        string             `bson:"tags"`
+	Variants    []ProductVariant   `bson:"variants"`
+	// ShopifyUpdatedAt is the updated_at of the product in Shopify, events
+	// older than it are ignored.
+	ShopifyUpdatedAt *time.Time `bson:"shopify_updated_at,omitempty"`
+	CreatedAt        *time.Time `bson:"created_at,omitempty"`
+	UpdatedAt        *time.Time `bson:"updated_at,omitempty"`
+	// DeletedAt is set when the product was deleted in Shopify, the
+	// document is kept for a while so that late events cannot restore it.
+	DeletedAt *time.Time `bson:"deleted_at,omitempty"`
+}
+
+type ProductVariant struct {
+	VariantID         int64  `bson:"variant_id"`
+	Title             string `bson:"title"`
+	Sku               string `bson:"sku"`
+	Barcode           string `bson:"barcode"`
+	Price             string `bson:"price"`
+	CompareAtPrice    string `bson:"compare_at_price"`
+	Position          int    `bson:"position"`
+	InventoryQuantity int    `bson:"inventory_quantity"`
+}
+
+func (p Product) IsEmpty() bool {
+	return p.ID.IsZero() &&
+		p.Shop == "" &&
+		p.ProductID == 0 &&
+		p.CreatedAt == nil &&
+		p.UpdatedAt == nil
+}
+
+// IsOlderThan reports whether p is an older version than other, a product
+// without Shopify date is always considered outdated. Versions with the same
+// date are not older: the Shopify dates have a second precision, so two
+// updates made in the same second must both be applied.
+func (p Product) IsOlderThan(other Product) bool {
+	if p.ShopifyUpdatedAt == nil {
+		return true
+	}
+
+	return other.ShopifyUpdatedAt != nil && p.ShopifyUpdatedAt.Before(*other.ShopifyUpdatedAt)
+}
+
+func (p *Product) SetID() {
+	if p.ID.IsZero() {
+		p.ID = primitive.NewObjectID()
+	}
+}
+
+func (p *Product) UpdateDate() {
+	now := time.Now().Truncate(time.Millisecond)
+	if p.CreatedAt == nil {
+		p.CreatedAt = &now
+	}
+
+	p.UpdatedAt = &now
+}
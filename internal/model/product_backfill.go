@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ProductBackfillRunning   = "running"
+	ProductBackfillCompleted = "completed"
+	ProductBackfillFailed    = "failed"
+	// ProductBackfillCancelled is the status of a backfill stopped by the
+	// uninstall of the shop.
+	ProductBackfillCancelled = "cancelled"
+)
+
+// ProductBackfill is the progress of the copy of every product of a shop
+// made after it installs the app, the webhooks only bring the later changes.
+type ProductBackfill struct {
+	ID     primitive.ObjectID `bson:"_id"`
+	Shop   string             `bson:"shop"`
+	Status string             `bson:"status"`
+	// SinceID is the id of the last product stored, products are listed by
+	// ascending id so that an interrupted backfill resumes after it.
+	SinceID int64 `bson:"since_id"`
+	// ProductCount is the number of products of the shop when the backfill
+	// started.
+	ProductCount int        `bson:"product_count"`
+	SyncedCount  int        `bson:"synced_count"`
+	Error        string     `bson:"error,omitempty"`
+	StartedAt    *time.Time `bson:"started_at,omitempty"`
+	FinishedAt   *time.Time `bson:"finished_at,omitempty"`
+	CreatedAt    *time.Time `bson:"created_at,omitempty"`
+	UpdatedAt    *time.Time `bson:"updated_at,omitempty"`
+}
+
+func (b ProductBackfill) IsEmpty() bool {
+	return b.ID.IsZero() &&
+		b.Shop == "" &&
+		b.Status == "" &&
+		b.StartedAt == nil &&
+		b.CreatedAt == nil &&
+		b.UpdatedAt == nil
+}
+
+func (b ProductBackfill) IsRunning() bool {
+	return b.Status == ProductBackfillRunning
+}
+
+func (b *ProductBackfill) SetID() {
+	if b.ID.IsZero() {
+		b.ID = primitive.NewObjectID()
+	}
+}
+
+func (b *ProductBackfill) UpdateDate() {
+	now := time.Now().Truncate(time.Millisecond)
+	if b.CreatedAt == nil {
+		b.CreatedAt = &now
+	}
+
+	b.UpdatedAt = &now
+}
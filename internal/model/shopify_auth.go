@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -10,15 +11,23 @@ type ShopifyAuth struct {
 	ID          primitive.ObjectID `bson:"_id"`
 	Shop        string             `bson:"shop"`
 	AccessToken string             `bson:"access_token"`
-	CreatedAt   *time.Time         `bson:"created_at,omitempty"`
-	UpdatedAt   *time.Time         `bson:"updated_at,omitempty"`
-	DeletedAt   *time.Time         `bson:"deleted_at,omitempty"`
+	// AccessTokenKeyID and AccessTokenDataKey are set when AccessToken is
+	// stored encrypted, see repository.NewEncryptedAuthRepository.
+	AccessTokenKeyID   string     `bson:"access_token_key_id,omitempty"`
+	AccessTokenDataKey string     `bson:"access_token_data_key,omitempty"`
+	Scope              string     `bson:"scope"`
+	CreatedAt          *time.Time `bson:"created_at,omitempty"`
+	UpdatedAt          *time.Time `bson:"updated_at,omitempty"`
+	DeletedAt          *time.Time `bson:"deleted_at,omitempty"`
 }
 
 func (s ShopifyAuth) IsEmpty() bool {
 	return s.ID.IsZero() &&
 		s.Shop == "" &&
 		s.AccessToken == "" &&
+		s.AccessTokenKeyID == "" &&
+		s.AccessTokenDataKey == "" &&
+		s.Scope == "" &&
 		s.CreatedAt == nil &&
 		s.UpdatedAt == nil &&
 		s.DeletedAt == nil
@@ -31,10 +40,34 @@ func (s *ShopifyAuth) SetID() {
 }
 
 func (s *ShopifyAuth) UpdateDate() {
-	now := time.Now()
+	// mongo stores dates with a millisecond precision, truncating keeps the
+	// returned dates comparable with the stored ones
+	now := time.Now().Truncate(time.Millisecond)
 	if s.CreatedAt == nil {
 		s.CreatedAt = &now
 	}
 
 	s.UpdatedAt = &now
 }
+
+// HasScopes reports whether every scope of the comma separated required
+// scopes was granted. A write scope implies the matching read scope.
+func (s ShopifyAuth) HasScopes(required string) bool {
+	granted := map[string]bool{}
+	for _, scope := range strings.Split(s.Scope, ",") {
+		scope = strings.TrimSpace(scope)
+		granted[scope] = true
+		if resource, ok := strings.CutPrefix(scope, "write_"); ok {
+			granted["read_"+resource] = true
+		}
+	}
+
+	for _, scope := range strings.Split(required, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope != "" && !granted[scope] {
+			return false
+		}
+	}
+
+	return true
+}
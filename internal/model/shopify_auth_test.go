@@ -0,0 +1,30 @@
+package model
+
+import "testing"
+
+func TestShopifyAuthHasScopes(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  string
+		required string
+		want     bool
+	}{
+		{"same scopes", "read_products,write_products", "read_products,write_products", true},
+		{"write implies read", "write_products", "read_products,write_products", true},
+		{"read does not imply write", "read_products", "read_products,write_products", false},
+		{"missing scope", "write_products", "write_products,read_orders", false},
+		{"extra scopes", "write_products,read_orders", "read_products", true},
+		{"spaces", "read_products, write_products", " write_products ", true},
+		{"nothing granted", "", "read_products", false},
+		{"nothing required", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := ShopifyAuth{Scope: tt.granted}
+			if got := auth.HasScopes(tt.required); got != tt.want {
+				t.Errorf("HasScopes(%q) with %q granted = %v, want %v", tt.required, tt.granted, got, tt.want)
+			}
+		})
+	}
+}
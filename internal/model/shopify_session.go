@@ -0,0 +1,68 @@
+package model
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ShopifySession holds the online access token of a staff member of a shop,
+// it expires along with the session of the user in the admin.
+type ShopifySession struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Shop        string             `bson:"shop"`
+	UserID      int64              `bson:"user_id"`
+	AccessToken string             `bson:"access_token"`
+	// AccessTokenKeyID and AccessTokenDataKey are set when AccessToken is
+	// stored encrypted, see repository.NewEncryptedSessionRepository.
+	AccessTokenKeyID    string         `bson:"access_token_key_id,omitempty"`
+	AccessTokenDataKey  string         `bson:"access_token_data_key,omitempty"`
+	Scope               string         `bson:"scope"`
+	AssociatedUserScope string         `bson:"associated_user_scope"`
+	AssociatedUser      AssociatedUser `bson:"associated_user"`
+	ExpiresAt           *time.Time     `bson:"expires_at,omitempty"`
+	CreatedAt           *time.Time     `bson:"created_at,omitempty"`
+	UpdatedAt           *time.Time     `bson:"updated_at,omitempty"`
+}
+
+// AssociatedUser is the staff member an online access token was issued for.
+type AssociatedUser struct {
+	FirstName     string `bson:"first_name"`
+	LastName      string `bson:"last_name"`
+	Email         string `bson:"email"`
+	EmailVerified bool   `bson:"email_verified"`
+	AccountOwner  bool   `bson:"account_owner"`
+	Locale        string `bson:"locale"`
+	Collaborator  bool   `bson:"collaborator"`
+}
+
+func (s ShopifySession) IsEmpty() bool {
+	return s.ID.IsZero() &&
+		s.Shop == "" &&
+		s.UserID == 0 &&
+		s.AccessToken == "" &&
+		s.ExpiresAt == nil &&
+		s.CreatedAt == nil &&
+		s.UpdatedAt == nil
+}
+
+// IsExpired reports whether the access token expires within margin, so that
+// it is not used for a request that would outlive it.
+func (s ShopifySession) IsExpired(margin time.Duration) bool {
+	return s.ExpiresAt != nil && s.ExpiresAt.Before(time.Now().Add(margin))
+}
+
+func (s *ShopifySession) SetID() {
+	if s.ID.IsZero() {
+		s.ID = primitive.NewObjectID()
+	}
+}
+
+func (s *ShopifySession) UpdateDate() {
+	now := time.Now().Truncate(time.Millisecond)
+	if s.CreatedAt == nil {
+		s.CreatedAt = &now
+	}
+
+	s.UpdatedAt = &now
+}
@@ -4,32 +4,64 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 const (
 	authCollection = "auth"
 )
 
+// ErrConflict is returned by Update when the document was modified since it
+// was read.
+var ErrConflict = errors.New("document was modified concurrently")
+
 type AuthRepository interface {
 	FindAll(ctx context.Context) ([]model.ShopifyAuth, error)
+	// FindActive returns the auth of every shop which has not uninstalled
+	// the app.
+	FindActive(ctx context.Context) ([]model.ShopifyAuth, error)
 	FindByShop(ctx context.Context, shop string) (model.ShopifyAuth, error)
+	// Save inserts a new auth, it fails when the shop already has one.
 	Save(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error)
+	// Update replaces the auth with the same ID, unless it was modified since
+	// data was read in which case ErrConflict is returned.
+	Update(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error)
+	// Upsert updates the access token and scope of the auth of data.Shop, it
+	// is created or restored when needed.
+	Upsert(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error)
+	// SoftDelete marks the auth of shop as deleted and clears its access
+	// token, FindByShop ignores it afterwards.
+	SoftDelete(ctx context.Context, shop string) error
+	// HardDelete removes every auth of shop, including the soft deleted ones.
+	HardDelete(ctx context.Context, shop string) error
 }
 
 type authRepository struct {
 	collection *mongo.Collection
 }
 
-func NewAuthRepository(db *mongo.Database) (AuthRepository, error) {
+func NewAuthRepository(ctx context.Context, db *mongo.Database) (AuthRepository, error) {
 	collection := db.Collection(authCollection)
 	if collection == nil {
 		return nil, fmt.Errorf("failed to get collection %s", authCollection)
 	}
 
+	// a shop has a single auth, soft deleted or not, so that FindByShop is
+	// never ambiguous
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "shop", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index on %s, duplicated shops must be removed first: %w", authCollection, err)
+	}
+
 	return &authRepository{
 		collection: collection,
 	}, nil
@@ -51,9 +83,28 @@ func (r *authRepository) FindAll(ctx context.Context) ([]model.ShopifyAuth, erro
 	return results, nil
 }
 
+func (r *authRepository) FindActive(ctx context.Context) ([]model.ShopifyAuth, error) {
+	filter := bson.M{}
+	filter["deleted_at"] = nil
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return []model.ShopifyAuth{}, err
+	}
+
+	var results []model.ShopifyAuth
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return []model.ShopifyAuth{}, err
+	}
+
+	return results, nil
+}
+
 func (r *authRepository) FindByShop(ctx context.Context, shop string) (model.ShopifyAuth, error) {
 	filter := bson.M{}
 	filter["shop"] = shop
+	filter["deleted_at"] = nil
 
 	var result model.ShopifyAuth
 	err := r.collection.FindOne(ctx, filter).Decode(&result)
@@ -78,3 +129,109 @@ func (r *authRepository) Save(ctx context.Context, data model.ShopifyAuth) (mode
 
 	return data, nil
 }
+
+func (r *authRepository) Update(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	filter := bson.M{}
+	filter["_id"] = data.ID
+	filter["updated_at"] = data.UpdatedAt
+
+	previous := data.UpdatedAt
+	data.UpdateDate()
+	data.UpdatedAt = nextVersion(previous, data.UpdatedAt)
+
+	result, err := r.collection.ReplaceOne(ctx, filter, &data)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	if result.MatchedCount == 0 {
+		return model.ShopifyAuth{}, ErrConflict
+	}
+
+	return data, nil
+}
+
+// nextVersion returns updated unless it is not after previous, e.g. when
+// both updates happened within the same millisecond. updated_at is the
+// version checked by Update, it must change on every update.
+func nextVersion(previous *time.Time, updated *time.Time) *time.Time {
+	if previous == nil || updated.After(*previous) {
+		return updated
+	}
+
+	next := previous.Add(time.Millisecond)
+	return &next
+}
+
+func (r *authRepository) Upsert(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	filter := bson.M{}
+	filter["shop"] = data.Shop
+
+	now := time.Now().Truncate(time.Millisecond)
+	update := bson.M{
+		"$set": bson.M{
+			"access_token":          data.AccessToken,
+			"access_token_key_id":   data.AccessTokenKeyID,
+			"access_token_data_key": data.AccessTokenDataKey,
+			"scope":                 data.Scope,
+			"updated_at":            now,
+		},
+		"$unset": bson.M{
+			"deleted_at": "",
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var result model.ShopifyAuth
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	return result, nil
+}
+
+func (r *authRepository) SoftDelete(ctx context.Context, shop string) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["deleted_at"] = nil
+
+	now := time.Now()
+	update := bson.M{
+		"$set": bson.M{
+			"access_token": "",
+			"updated_at":   now,
+			"deleted_at":   now,
+		},
+		"$unset": bson.M{
+			"access_token_key_id":   "",
+			"access_token_data_key": "",
+		},
+	}
+
+	_, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *authRepository) HardDelete(ctx context.Context, shop string) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+
+	_, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	complianceCollection = "compliance_request"
+)
+
+type ComplianceRepository interface {
+	Save(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error)
+	Update(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error)
+}
+
+type complianceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewComplianceRepository(db *mongo.Database) (ComplianceRepository, error) {
+	collection := db.Collection(complianceCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", complianceCollection)
+	}
+
+	return &complianceRepository{
+		collection: collection,
+	}, nil
+}
+
+func (r *complianceRepository) Save(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error) {
+	data.SetID()
+	data.UpdateDate()
+
+	_, err := r.collection.InsertOne(ctx, &data)
+	if err != nil {
+		return model.ComplianceRequest{}, err
+	}
+
+	return data, nil
+}
+
+func (r *complianceRepository) Update(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error) {
+	data.UpdateDate()
+
+	filter := bson.M{}
+	filter["_id"] = data.ID
+
+	_, err := r.collection.ReplaceOne(ctx, filter, &data)
+	if err != nil {
+		return model.ComplianceRequest{}, err
+	}
+
+	return data, nil
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/encryption"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+)
+
+// EncryptedAuthRepository stores the access tokens encrypted, callers only
+// ever see them in plaintext.
+type EncryptedAuthRepository interface {
+	AuthRepository
+	// RotateKeys encrypts the access token of every auth with the primary
+	// key of the keyring, tokens still stored in plaintext included. It can
+	// run while the application is serving, as long as the application
+	// keyring contains every key in use.
+	RotateKeys(ctx context.Context) (int, error)
+}
+
+type encryptedAuthRepository struct {
+	AuthRepository
+	keyring *encryption.Keyring
+}
+
+func NewEncryptedAuthRepository(
+	authRepository AuthRepository,
+	keyring *encryption.Keyring,
+) (EncryptedAuthRepository, error) {
+	if keyring == nil {
+		return nil, errors.New("keyring is required")
+	}
+
+	return &encryptedAuthRepository{
+		AuthRepository: authRepository,
+		keyring:        keyring,
+	}, nil
+}
+
+func (r *encryptedAuthRepository) FindAll(ctx context.Context) ([]model.ShopifyAuth, error) {
+	results, err := r.AuthRepository.FindAll(ctx)
+	if err != nil {
+		return []model.ShopifyAuth{}, err
+	}
+
+	return r.decryptAll(results)
+}
+
+func (r *encryptedAuthRepository) FindActive(ctx context.Context) ([]model.ShopifyAuth, error) {
+	results, err := r.AuthRepository.FindActive(ctx)
+	if err != nil {
+		return []model.ShopifyAuth{}, err
+	}
+
+	return r.decryptAll(results)
+}
+
+func (r *encryptedAuthRepository) FindByShop(ctx context.Context, shop string) (model.ShopifyAuth, error) {
+	result, err := r.AuthRepository.FindByShop(ctx, shop)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	return r.decrypt(result)
+}
+
+func (r *encryptedAuthRepository) Save(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	encrypted, err := r.encrypt(data)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	result, err := r.AuthRepository.Save(ctx, encrypted)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	return r.decrypt(result)
+}
+
+func (r *encryptedAuthRepository) Update(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	encrypted, err := r.encrypt(data)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	result, err := r.AuthRepository.Update(ctx, encrypted)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	return r.decrypt(result)
+}
+
+func (r *encryptedAuthRepository) Upsert(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	encrypted, err := r.encrypt(data)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	result, err := r.AuthRepository.Upsert(ctx, encrypted)
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	return r.decrypt(result)
+}
+
+func (r *encryptedAuthRepository) RotateKeys(ctx context.Context) (int, error) {
+	results, err := r.AuthRepository.FindAll(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, result := range results {
+		if result.AccessToken == "" || result.AccessTokenKeyID == r.keyring.PrimaryKeyID() {
+			continue
+		}
+
+		shop := result.Shop
+		if result.AccessTokenKeyID == "" {
+			result, err = r.encrypt(result)
+		} else {
+			result, err = r.rewrap(result)
+		}
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rotate auth of %s: %w", shop, err)
+		}
+
+		_, err = r.AuthRepository.Update(ctx, result)
+		if errors.Is(err, ErrConflict) {
+			// written meanwhile, hence already with the primary key
+			log.Info().Str("shop", shop).Msg("auth modified during rotation, skipped")
+			continue
+		}
+		if err != nil {
+			return rotated, fmt.Errorf("failed to rotate auth of %s: %w", shop, err)
+		}
+		rotated++
+	}
+
+	return rotated, nil
+}
+
+func (r *encryptedAuthRepository) encrypt(data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	if data.AccessToken == "" {
+		data.AccessTokenKeyID = ""
+		data.AccessTokenDataKey = ""
+		return data, nil
+	}
+
+	envelope, err := r.keyring.Encrypt([]byte(data.AccessToken), []byte(data.Shop))
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	data.AccessToken = envelope.Ciphertext
+	data.AccessTokenKeyID = envelope.KeyID
+	data.AccessTokenDataKey = envelope.DataKey
+
+	return data, nil
+}
+
+func (r *encryptedAuthRepository) rewrap(data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	envelope, err := r.keyring.Rewrap(encryption.Envelope{
+		KeyID:      data.AccessTokenKeyID,
+		DataKey:    data.AccessTokenDataKey,
+		Ciphertext: data.AccessToken,
+	})
+	if err != nil {
+		return model.ShopifyAuth{}, err
+	}
+
+	data.AccessTokenKeyID = envelope.KeyID
+	data.AccessTokenDataKey = envelope.DataKey
+
+	return data, nil
+}
+
+// decrypt returns data with its access token in plaintext. Tokens stored
+// before encryption was enabled are returned as is until RotateKeys runs.
+func (r *encryptedAuthRepository) decrypt(data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	if data.AccessTokenKeyID == "" {
+		return data, nil
+	}
+
+	plaintext, err := r.keyring.Decrypt(encryption.Envelope{
+		KeyID:      data.AccessTokenKeyID,
+		DataKey:    data.AccessTokenDataKey,
+		Ciphertext: data.AccessToken,
+	}, []byte(data.Shop))
+	if err != nil {
+		return model.ShopifyAuth{}, fmt.Errorf("failed to decrypt access token of %s: %w", data.Shop, err)
+	}
+
+	data.AccessToken = string(plaintext)
+	data.AccessTokenKeyID = ""
+	data.AccessTokenDataKey = ""
+
+	return data, nil
+}
+
+func (r *encryptedAuthRepository) decryptAll(data []model.ShopifyAuth) ([]model.ShopifyAuth, error) {
+	results := make([]model.ShopifyAuth, 0, len(data))
+	for _, d := range data {
+		result, err := r.decrypt(d)
+		if err != nil {
+			return []model.ShopifyAuth{}, err
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strconv"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/encryption"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+)
+
+type encryptedSessionRepository struct {
+	SessionRepository
+	keyring *encryption.Keyring
+}
+
+// NewEncryptedSessionRepository stores the online access tokens encrypted
+// like NewEncryptedAuthRepository. Sessions only live for a day, so there is
+// no rotation: a session encrypted with a key that was removed from the
+// keyring is reported as missing, and issued again.
+func NewEncryptedSessionRepository(
+	sessionRepository SessionRepository,
+	keyring *encryption.Keyring,
+) (SessionRepository, error) {
+	if keyring == nil {
+		return nil, errors.New("keyring is required")
+	}
+
+	return &encryptedSessionRepository{
+		SessionRepository: sessionRepository,
+		keyring:           keyring,
+	}, nil
+}
+
+func (r *encryptedSessionRepository) FindByShopAndUser(ctx context.Context, shop string, userId int64) (model.ShopifySession, error) {
+	result, err := r.SessionRepository.FindByShopAndUser(ctx, shop, userId)
+	if err != nil || result.IsEmpty() {
+		return result, err
+	}
+
+	decrypted, err := r.decrypt(result)
+	if err != nil {
+		log.Ctx(ctx).Warn().Err(err).Int64("user_id", userId).Msg("failed to decrypt session, ignored")
+		return model.ShopifySession{}, nil
+	}
+
+	return decrypted, nil
+}
+
+func (r *encryptedSessionRepository) Upsert(ctx context.Context, data model.ShopifySession) (model.ShopifySession, error) {
+	envelope, err := r.keyring.Encrypt([]byte(data.AccessToken), sessionAdditionalData(data))
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	data.AccessToken = envelope.Ciphertext
+	data.AccessTokenKeyID = envelope.KeyID
+	data.AccessTokenDataKey = envelope.DataKey
+
+	result, err := r.SessionRepository.Upsert(ctx, data)
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	return r.decrypt(result)
+}
+
+func (r *encryptedSessionRepository) decrypt(data model.ShopifySession) (model.ShopifySession, error) {
+	plaintext, err := r.keyring.Decrypt(encryption.Envelope{
+		KeyID:      data.AccessTokenKeyID,
+		DataKey:    data.AccessTokenDataKey,
+		Ciphertext: data.AccessToken,
+	}, sessionAdditionalData(data))
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	data.AccessToken = string(plaintext)
+	data.AccessTokenKeyID = ""
+	data.AccessTokenDataKey = ""
+
+	return data, nil
+}
+
+// sessionAdditionalData binds the token to its shop and user, so that it
+// cannot be copied to another session.
+func sessionAdditionalData(data model.ShopifySession) []byte {
+	return []byte(data.Shop + "/" + strconv.FormatInt(data.UserID, 10))
+}
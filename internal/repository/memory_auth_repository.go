@@ -0,0 +1,153 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryAuthRepository struct {
+	mutex sync.RWMutex
+	auths map[string]model.ShopifyAuth
+}
+
+// NewMemoryAuthRepository returns an AuthRepository keeping the auths in
+// memory, with the same semantics as the mongo one.
+func NewMemoryAuthRepository() AuthRepository {
+	return &memoryAuthRepository{
+		auths: map[string]model.ShopifyAuth{},
+	}
+}
+
+func (r *memoryAuthRepository) FindAll(ctx context.Context) ([]model.ShopifyAuth, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	results := []model.ShopifyAuth{}
+	for _, auth := range r.auths {
+		results = append(results, auth)
+	}
+
+	return results, nil
+}
+
+func (r *memoryAuthRepository) FindActive(ctx context.Context) ([]model.ShopifyAuth, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	results := []model.ShopifyAuth{}
+	for _, auth := range r.auths {
+		if auth.DeletedAt == nil {
+			results = append(results, auth)
+		}
+	}
+
+	return results, nil
+}
+
+func (r *memoryAuthRepository) FindByShop(ctx context.Context, shop string) (model.ShopifyAuth, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	auth, ok := r.auths[shop]
+	if !ok || auth.DeletedAt != nil {
+		return model.ShopifyAuth{}, nil
+	}
+
+	return auth, nil
+}
+
+func (r *memoryAuthRepository) Save(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.auths[data.Shop]; ok {
+		return model.ShopifyAuth{}, fmt.Errorf("auth of %s already exists", data.Shop)
+	}
+
+	data.SetID()
+	data.UpdateDate()
+	r.auths[data.Shop] = data
+
+	return data, nil
+}
+
+func (r *memoryAuthRepository) Update(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	auth, ok := r.auths[data.Shop]
+	if !ok || auth.ID != data.ID || !equalTime(auth.UpdatedAt, data.UpdatedAt) {
+		return model.ShopifyAuth{}, ErrConflict
+	}
+
+	data.UpdateDate()
+	data.UpdatedAt = nextVersion(auth.UpdatedAt, data.UpdatedAt)
+	r.auths[data.Shop] = data
+
+	return data, nil
+}
+
+func (r *memoryAuthRepository) Upsert(ctx context.Context, data model.ShopifyAuth) (model.ShopifyAuth, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	auth, ok := r.auths[data.Shop]
+	if !ok {
+		auth = model.ShopifyAuth{
+			ID:   primitive.NewObjectID(),
+			Shop: data.Shop,
+		}
+	}
+
+	auth.AccessToken = data.AccessToken
+	auth.AccessTokenKeyID = data.AccessTokenKeyID
+	auth.AccessTokenDataKey = data.AccessTokenDataKey
+	auth.Scope = data.Scope
+	auth.DeletedAt = nil
+	auth.UpdateDate()
+	r.auths[data.Shop] = auth
+
+	return auth, nil
+}
+
+func (r *memoryAuthRepository) SoftDelete(ctx context.Context, shop string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	auth, ok := r.auths[shop]
+	if !ok || auth.DeletedAt != nil {
+		return nil
+	}
+
+	now := time.Now()
+	auth.AccessToken = ""
+	auth.AccessTokenKeyID = ""
+	auth.AccessTokenDataKey = ""
+	auth.UpdatedAt = &now
+	auth.DeletedAt = &now
+	r.auths[shop] = auth
+
+	return nil
+}
+
+func (r *memoryAuthRepository) HardDelete(ctx context.Context, shop string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.auths, shop)
+
+	return nil
+}
+
+func equalTime(a *time.Time, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
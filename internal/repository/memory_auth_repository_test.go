@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/encryption"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+)
+
+const testShop = "shop.myshopify.com"
+
+func TestMemoryAuthRepositoryNotFound(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryAuthRepository()
+
+	auth, err := repo.FindByShop(ctx, testShop)
+	if err != nil {
+		t.Fatalf("FindByShop() error = %v", err)
+	}
+	if !auth.IsEmpty() {
+		t.Errorf("FindByShop() = %+v, want an empty auth", auth)
+	}
+
+	if err := repo.SoftDelete(ctx, testShop); err != nil {
+		t.Errorf("SoftDelete() error = %v", err)
+	}
+	if err := repo.HardDelete(ctx, testShop); err != nil {
+		t.Errorf("HardDelete() error = %v", err)
+	}
+}
+
+func TestMemoryAuthRepositoryLifecycle(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryAuthRepository()
+
+	installed, err := repo.Upsert(ctx, model.ShopifyAuth{Shop: testShop, AccessToken: "token1", Scope: "read_products"})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if installed.IsEmpty() || installed.ID.IsZero() || installed.CreatedAt == nil {
+		t.Fatalf("Upsert() = %+v, want a stored auth", installed)
+	}
+
+	if _, err := repo.Save(ctx, model.ShopifyAuth{Shop: testShop}); err == nil {
+		t.Error("Save() succeeded for a shop which already has an auth")
+	}
+
+	if err := repo.SoftDelete(ctx, testShop); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+
+	auth, err := repo.FindByShop(ctx, testShop)
+	if err != nil || !auth.IsEmpty() {
+		t.Errorf("FindByShop() after SoftDelete = %+v, %v, want an empty auth", auth, err)
+	}
+
+	active, _ := repo.FindActive(ctx)
+	if len(active) != 0 {
+		t.Errorf("FindActive() after SoftDelete = %+v, want none", active)
+	}
+
+	all, _ := repo.FindAll(ctx)
+	if len(all) != 1 || all[0].DeletedAt == nil || all[0].AccessToken != "" {
+		t.Errorf("FindAll() after SoftDelete = %+v, want the deleted auth without token", all)
+	}
+
+	// a reinstall restores the same auth
+	reinstalled, err := repo.Upsert(ctx, model.ShopifyAuth{Shop: testShop, AccessToken: "token2", Scope: "write_products"})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if reinstalled.ID != installed.ID || reinstalled.DeletedAt != nil || reinstalled.AccessToken != "token2" {
+		t.Errorf("Upsert() after SoftDelete = %+v, want auth %s restored", reinstalled, installed.ID.Hex())
+	}
+
+	if err := repo.HardDelete(ctx, testShop); err != nil {
+		t.Fatalf("HardDelete() error = %v", err)
+	}
+	all, _ = repo.FindAll(ctx)
+	if len(all) != 0 {
+		t.Errorf("FindAll() after HardDelete = %+v, want none", all)
+	}
+}
+
+func TestMemoryAuthRepositoryUpdateConflict(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryAuthRepository()
+
+	stored, err := repo.Save(ctx, model.ShopifyAuth{Shop: testShop, AccessToken: "token1"})
+	if err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	stored.AccessToken = "token2"
+	updated, err := repo.Update(ctx, stored)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+
+	// stored was read before the previous update
+	stored.AccessToken = "token3"
+	if _, err := repo.Update(ctx, stored); !errors.Is(err, ErrConflict) {
+		t.Errorf("Update() of a stale auth error = %v, want ErrConflict", err)
+	}
+
+	auth, _ := repo.FindByShop(ctx, testShop)
+	if auth.AccessToken != updated.AccessToken {
+		t.Errorf("FindByShop() token = %q, want %q", auth.AccessToken, updated.AccessToken)
+	}
+}
+
+func TestEncryptedAuthRepository(t *testing.T) {
+	ctx := context.Background()
+	keyring, err := encryption.NewKeyring("key1", map[string][]byte{"key1": bytes.Repeat([]byte{1}, 32)})
+	if err != nil {
+		t.Fatalf("NewKeyring() error = %v", err)
+	}
+
+	memory := NewMemoryAuthRepository()
+	repo, err := NewEncryptedAuthRepository(memory, keyring)
+	if err != nil {
+		t.Fatalf("NewEncryptedAuthRepository() error = %v", err)
+	}
+
+	auth, err := repo.FindByShop(ctx, testShop)
+	if err != nil || !auth.IsEmpty() {
+		t.Fatalf("FindByShop() = %+v, %v, want an empty auth", auth, err)
+	}
+
+	if _, err := repo.Upsert(ctx, model.ShopifyAuth{Shop: testShop, AccessToken: "shpat_secret"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	stored, _ := memory.FindByShop(ctx, testShop)
+	if stored.AccessToken == "shpat_secret" || stored.AccessTokenKeyID != "key1" {
+		t.Errorf("stored auth = %+v, want an encrypted access token", stored)
+	}
+
+	auth, err = repo.FindByShop(ctx, testShop)
+	if err != nil {
+		t.Fatalf("FindByShop() error = %v", err)
+	}
+	if auth.AccessToken != "shpat_secret" || auth.AccessTokenKeyID != "" {
+		t.Errorf("FindByShop() = %+v, want the decrypted access token", auth)
+	}
+}
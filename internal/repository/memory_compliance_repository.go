@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryComplianceRepository struct {
+	mutex    sync.Mutex
+	requests map[primitive.ObjectID]model.ComplianceRequest
+}
+
+// NewMemoryComplianceRepository returns a ComplianceRepository keeping the
+// requests in memory.
+func NewMemoryComplianceRepository() ComplianceRepository {
+	return &memoryComplianceRepository{
+		requests: map[primitive.ObjectID]model.ComplianceRequest{},
+	}
+}
+
+func (r *memoryComplianceRepository) Save(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data.SetID()
+	data.UpdateDate()
+	r.requests[data.ID] = data
+
+	return data, nil
+}
+
+func (r *memoryComplianceRepository) Update(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.requests[data.ID]; !ok {
+		return model.ComplianceRequest{}, fmt.Errorf("compliance request %s not found", data.ID.Hex())
+	}
+
+	data.UpdateDate()
+	r.requests[data.ID] = data
+
+	return data, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+)
+
+type memoryNonceRepository struct {
+	mutex  sync.Mutex
+	nonces map[string]model.OauthNonce
+}
+
+// NewMemoryNonceRepository returns a NonceRepository keeping the nonces in
+// memory. Expired nonces are removed when a new one is saved.
+func NewMemoryNonceRepository() NonceRepository {
+	return &memoryNonceRepository{
+		nonces: map[string]model.OauthNonce{},
+	}
+}
+
+func (r *memoryNonceRepository) Save(ctx context.Context, data model.OauthNonce) (model.OauthNonce, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, nonce := range r.nonces {
+		if nonce.IsExpired() {
+			delete(r.nonces, key)
+		}
+	}
+
+	data.SetID()
+	data.UpdateDate()
+	r.nonces[data.Shop+"/"+data.Nonce] = data
+
+	return data, nil
+}
+
+func (r *memoryNonceRepository) Consume(ctx context.Context, shop string, nonce string) (model.OauthNonce, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := shop + "/" + nonce
+	result, ok := r.nonces[key]
+	if !ok {
+		return model.OauthNonce{}, nil
+	}
+	delete(r.nonces, key)
+
+	return result, nil
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryProductBackfillRepository struct {
+	mutex     sync.RWMutex
+	backfills map[string]model.ProductBackfill
+}
+
+// NewMemoryProductBackfillRepository returns a ProductBackfillRepository
+// keeping the backfills in memory, there is nothing to resume after a
+// restart.
+func NewMemoryProductBackfillRepository() ProductBackfillRepository {
+	return &memoryProductBackfillRepository{
+		backfills: map[string]model.ProductBackfill{},
+	}
+}
+
+func (r *memoryProductBackfillRepository) FindByShop(ctx context.Context, shop string) (model.ProductBackfill, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.backfills[shop], nil
+}
+
+func (r *memoryProductBackfillRepository) FindRunning(ctx context.Context) ([]model.ProductBackfill, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	results := []model.ProductBackfill{}
+	for _, backfill := range r.backfills {
+		if backfill.IsRunning() {
+			results = append(results, backfill)
+		}
+	}
+
+	return results, nil
+}
+
+func (r *memoryProductBackfillRepository) Upsert(ctx context.Context, data model.ProductBackfill) (model.ProductBackfill, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	backfill, ok := r.backfills[data.Shop]
+	if !ok {
+		backfill = model.ProductBackfill{
+			ID:   primitive.NewObjectID(),
+			Shop: data.Shop,
+		}
+	}
+
+	backfill.Status = data.Status
+	backfill.SinceID = data.SinceID
+	backfill.ProductCount = data.ProductCount
+	backfill.SyncedCount = data.SyncedCount
+	backfill.Error = data.Error
+	backfill.StartedAt = data.StartedAt
+	backfill.FinishedAt = data.FinishedAt
+	backfill.UpdateDate()
+	r.backfills[data.Shop] = backfill
+
+	return backfill, nil
+}
+
+func (r *memoryProductBackfillRepository) DeleteByShop(ctx context.Context, shop string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	delete(r.backfills, shop)
+
+	return nil
+}
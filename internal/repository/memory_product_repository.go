@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memoryProductRepository struct {
+	mutex    sync.RWMutex
+	products map[string]model.Product
+}
+
+// NewMemoryProductRepository returns a ProductRepository keeping the
+// products in memory, with the same semantics as the mongo one. Expired
+// tombstones are ignored, and removed when another product is deleted.
+func NewMemoryProductRepository() ProductRepository {
+	return &memoryProductRepository{
+		products: map[string]model.Product{},
+	}
+}
+
+func (r *memoryProductRepository) FindByShop(ctx context.Context, shop string) ([]model.Product, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	results := []model.Product{}
+	for _, product := range r.products {
+		if product.Shop == shop && product.DeletedAt == nil {
+			results = append(results, product)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ProductID < results[j].ProductID })
+
+	return results, nil
+}
+
+func (r *memoryProductRepository) FindByShopAndProductID(ctx context.Context, shop string, productId int64) (model.Product, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	product, ok := r.products[productKey(shop, productId)]
+	if !ok || product.DeletedAt != nil {
+		return model.Product{}, nil
+	}
+
+	return product, nil
+}
+
+func (r *memoryProductRepository) Upsert(ctx context.Context, data model.Product) (bool, error) {
+	if data.ShopifyUpdatedAt == nil {
+		return false, errProductUpdatedAtRequired
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	key := productKey(data.Shop, data.ProductID)
+	product, ok := r.products[key]
+	if ok && isExpiredTombstone(product) {
+		ok = false
+	}
+	if ok && (product.DeletedAt != nil || data.IsOlderThan(product)) {
+		return false, nil
+	}
+
+	if !ok {
+		product = model.Product{
+			ID:        primitive.NewObjectID(),
+			Shop:      data.Shop,
+			ProductID: data.ProductID,
+		}
+	}
+
+	product.Title = data.Title
+	product.BodyHTML = data.BodyHTML
+	product.Vendor = data.Vendor
+	product.ProductType = data.ProductType
+	product.Handle = data.Handle
+	product.Status = data.Status
+	product.Tags = data.Tags
+	product.Variants = data.Variants
+	product.ShopifyUpdatedAt = data.ShopifyUpdatedAt
+	product.UpdateDate()
+	r.products[key] = product
+
+	return true, nil
+}
+
+func (r *memoryProductRepository) Delete(ctx context.Context, shop string, productId int64) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, product := range r.products {
+		if isExpiredTombstone(product) {
+			delete(r.products, key)
+		}
+	}
+
+	key := productKey(shop, productId)
+	product, ok := r.products[key]
+	if !ok {
+		product = model.Product{
+			ID:        primitive.NewObjectID(),
+			Shop:      shop,
+			ProductID: productId,
+		}
+	}
+
+	product.Variants = []model.ProductVariant{}
+	product.UpdateDate()
+	product.DeletedAt = product.UpdatedAt
+	r.products[key] = product
+
+	return nil
+}
+
+func (r *memoryProductRepository) DeleteByShop(ctx context.Context, shop string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, product := range r.products {
+		if product.Shop == shop {
+			delete(r.products, key)
+		}
+	}
+
+	return nil
+}
+
+func isExpiredTombstone(product model.Product) bool {
+	return product.DeletedAt != nil && time.Since(*product.DeletedAt) > productTombstoneTTL
+}
+
+func productKey(shop string, productId int64) string {
+	return shop + "/" + strconv.FormatInt(productId, 10)
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+)
+
+func testProduct(title string, updatedAt time.Time) model.Product {
+	return model.Product{Shop: testShop, ProductID: 1, Title: title, ShopifyUpdatedAt: &updatedAt}
+}
+
+func TestMemoryProductRepositoryUpsert(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryProductRepository()
+	updatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		product   model.Product
+		want      bool
+		wantTitle string
+	}{
+		{"create", testProduct("v1", updatedAt), true, "v1"},
+		{"newer update", testProduct("v2", updatedAt.Add(time.Minute)), true, "v2"},
+		{"stale update", testProduct("stale", updatedAt), false, "v2"},
+		// a second update made in the same second
+		{"equal updated_at", testProduct("equal", updatedAt.Add(time.Minute)), true, "equal"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := repo.Upsert(ctx, tt.product)
+			if err != nil {
+				t.Fatalf("Upsert() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Upsert() = %v, want %v", got, tt.want)
+			}
+
+			stored, _ := repo.FindByShopAndProductID(ctx, testShop, 1)
+			if stored.Title != tt.wantTitle {
+				t.Errorf("stored title = %q, want %q", stored.Title, tt.wantTitle)
+			}
+		})
+	}
+
+	if _, err := repo.Upsert(ctx, model.Product{Shop: testShop, ProductID: 1}); err == nil {
+		t.Error("Upsert() succeeded without the shopify updated_at")
+	}
+}
+
+func TestMemoryProductRepositoryUpsertAfterDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryProductRepository()
+	updatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// products/delete can arrive before products/create
+	if err := repo.Delete(ctx, testShop, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	got, err := repo.Upsert(ctx, testProduct("created", updatedAt))
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if got {
+		t.Error("Upsert() of a deleted product = true, want false")
+	}
+
+	product, _ := repo.FindByShopAndProductID(ctx, testShop, 1)
+	if !product.IsEmpty() {
+		t.Errorf("FindByShopAndProductID() = %+v, want the product to stay deleted", product)
+	}
+}
+
+func TestMemoryProductRepositoryUpsertAfterTombstoneExpired(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryProductRepository()
+	updatedAt := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if err := repo.Delete(ctx, testShop, 1); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+
+	// expire the tombstone without deleting another product
+	memory := repo.(*memoryProductRepository)
+	key := productKey(testShop, 1)
+	tombstone := memory.products[key]
+	deletedAt := time.Now().Add(-productTombstoneTTL - time.Minute)
+	tombstone.DeletedAt = &deletedAt
+	memory.products[key] = tombstone
+
+	got, err := repo.Upsert(ctx, testProduct("recreated", updatedAt))
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if !got {
+		t.Error("Upsert() after the tombstone expired = false, want true")
+	}
+
+	product, _ := repo.FindByShopAndProductID(ctx, testShop, 1)
+	if product.Title != "recreated" || product.DeletedAt != nil {
+		t.Errorf("FindByShopAndProductID() = %+v, want the recreated product", product)
+	}
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"strconv"
+	"sync"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type memorySessionRepository struct {
+	mutex    sync.RWMutex
+	sessions map[string]model.ShopifySession
+}
+
+// NewMemorySessionRepository returns a SessionRepository keeping the
+// sessions in memory. Expired sessions are removed when one is upserted.
+func NewMemorySessionRepository() SessionRepository {
+	return &memorySessionRepository{
+		sessions: map[string]model.ShopifySession{},
+	}
+}
+
+func (r *memorySessionRepository) FindByShopAndUser(ctx context.Context, shop string, userId int64) (model.ShopifySession, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	return r.sessions[sessionKey(shop, userId)], nil
+}
+
+func (r *memorySessionRepository) Upsert(ctx context.Context, data model.ShopifySession) (model.ShopifySession, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, session := range r.sessions {
+		if session.IsExpired(0) {
+			delete(r.sessions, key)
+		}
+	}
+
+	key := sessionKey(data.Shop, data.UserID)
+	session, ok := r.sessions[key]
+	if !ok {
+		session = model.ShopifySession{
+			ID:     primitive.NewObjectID(),
+			Shop:   data.Shop,
+			UserID: data.UserID,
+		}
+	}
+
+	session.AccessToken = data.AccessToken
+	session.AccessTokenKeyID = data.AccessTokenKeyID
+	session.AccessTokenDataKey = data.AccessTokenDataKey
+	session.Scope = data.Scope
+	session.AssociatedUserScope = data.AssociatedUserScope
+	session.AssociatedUser = data.AssociatedUser
+	session.ExpiresAt = data.ExpiresAt
+	session.UpdateDate()
+	r.sessions[key] = session
+
+	return session, nil
+}
+
+func (r *memorySessionRepository) DeleteByShop(ctx context.Context, shop string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for key, session := range r.sessions {
+		if session.Shop == shop {
+			delete(r.sessions, key)
+		}
+	}
+
+	return nil
+}
+
+func sessionKey(shop string, userId int64) string {
+	return shop + "/" + strconv.FormatInt(userId, 10)
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	nonceCollection = "oauth_nonce"
+)
+
+type NonceRepository interface {
+	Save(ctx context.Context, data model.OauthNonce) (model.OauthNonce, error)
+	// Consume deletes and returns the nonce of shop, so it can only be used
+	// once. An empty OauthNonce is returned when it does not exist.
+	Consume(ctx context.Context, shop string, nonce string) (model.OauthNonce, error)
+}
+
+type nonceRepository struct {
+	collection *mongo.Collection
+}
+
+func NewNonceRepository(ctx context.Context, db *mongo.Database) (NonceRepository, error) {
+	collection := db.Collection(nonceCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", nonceCollection)
+	}
+
+	// let mongo remove the nonces that were never consumed
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index on %s: %w", nonceCollection, err)
+	}
+
+	return &nonceRepository{
+		collection: collection,
+	}, nil
+}
+
+func (r *nonceRepository) Save(ctx context.Context, data model.OauthNonce) (model.OauthNonce, error) {
+	data.SetID()
+	data.UpdateDate()
+
+	_, err := r.collection.InsertOne(ctx, &data)
+	if err != nil {
+		return model.OauthNonce{}, err
+	}
+
+	return data, nil
+}
+
+func (r *nonceRepository) Consume(ctx context.Context, shop string, nonce string) (model.OauthNonce, error) {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["nonce"] = nonce
+
+	var result model.OauthNonce
+	err := r.collection.FindOneAndDelete(ctx, filter).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.OauthNonce{}, nil
+		}
+		return model.OauthNonce{}, err
+	}
+
+	return result, nil
+}
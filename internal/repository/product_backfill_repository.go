@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	productBackfillCollection = "product_backfill"
+)
+
+type ProductBackfillRepository interface {
+	// FindByShop returns the last backfill of shop, an empty ProductBackfill
+	// is returned when it does not exist.
+	FindByShop(ctx context.Context, shop string) (model.ProductBackfill, error)
+	// FindRunning returns the backfills which did not finish, e.g. because
+	// the app was stopped.
+	FindRunning(ctx context.Context) ([]model.ProductBackfill, error)
+	// Upsert replaces the backfill of data.Shop, it is created when needed.
+	Upsert(ctx context.Context, data model.ProductBackfill) (model.ProductBackfill, error)
+	DeleteByShop(ctx context.Context, shop string) error
+}
+
+type productBackfillRepository struct {
+	collection *mongo.Collection
+}
+
+func NewProductBackfillRepository(ctx context.Context, db *mongo.Database) (ProductBackfillRepository, error) {
+	collection := db.Collection(productBackfillCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", productBackfillCollection)
+	}
+
+	_, err := collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "shop", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index on %s: %w", productBackfillCollection, err)
+	}
+
+	return &productBackfillRepository{
+		collection: collection,
+	}, nil
+}
+
+func (r *productBackfillRepository) FindByShop(ctx context.Context, shop string) (model.ProductBackfill, error) {
+	filter := bson.M{}
+	filter["shop"] = shop
+
+	var result model.ProductBackfill
+	err := r.collection.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.ProductBackfill{}, nil
+		}
+		return model.ProductBackfill{}, err
+	}
+
+	return result, nil
+}
+
+func (r *productBackfillRepository) FindRunning(ctx context.Context) ([]model.ProductBackfill, error) {
+	filter := bson.M{}
+	filter["status"] = model.ProductBackfillRunning
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return []model.ProductBackfill{}, err
+	}
+
+	var results []model.ProductBackfill
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return []model.ProductBackfill{}, err
+	}
+
+	return results, nil
+}
+
+func (r *productBackfillRepository) Upsert(ctx context.Context, data model.ProductBackfill) (model.ProductBackfill, error) {
+	filter := bson.M{}
+	filter["shop"] = data.Shop
+
+	now := time.Now().Truncate(time.Millisecond)
+	update := bson.M{
+		"$set": bson.M{
+			"status":        data.Status,
+			"since_id":      data.SinceID,
+			"product_count": data.ProductCount,
+			"synced_count":  data.SyncedCount,
+			"error":         data.Error,
+			"started_at":    data.StartedAt,
+			"finished_at":   data.FinishedAt,
+			"updated_at":    now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var result model.ProductBackfill
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return model.ProductBackfill{}, err
+	}
+
+	return result, nil
+}
+
+func (r *productBackfillRepository) DeleteByShop(ctx context.Context, shop string) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+
+	_, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
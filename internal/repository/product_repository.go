@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	productCollection = "product"
+	// productTombstoneTTL is how long deleted products are kept to ignore
+	// the events delivered after their deletion, Shopify retries webhooks
+	// for 48 hours.
+	productTombstoneTTL = 7 * 24 * time.Hour
+)
+
+type ProductRepository interface {
+	// FindByShop returns the products of shop which were not deleted.
+	FindByShop(ctx context.Context, shop string) ([]model.Product, error)
+	// FindByShopAndProductID returns a product which was not deleted, an
+	// empty Product is returned when it does not exist.
+	FindByShopAndProductID(ctx context.Context, shop string, productId int64) (model.Product, error)
+	// Upsert stores data unless the stored product is more recent or was
+	// deleted, and reports whether it was stored. data.ShopifyUpdatedAt is
+	// required.
+	Upsert(ctx context.Context, data model.Product) (bool, error)
+	// Delete marks the product as deleted, it is created as such if it was
+	// never stored so that a late creation event is ignored.
+	Delete(ctx context.Context, shop string, productId int64) error
+	// DeleteByShop removes every product of shop, including the deleted ones.
+	DeleteByShop(ctx context.Context, shop string) error
+}
+
+var errProductUpdatedAtRequired = errors.New("shopify updated_at of the product is required")
+
+type productRepository struct {
+	collection *mongo.Collection
+}
+
+func NewProductRepository(ctx context.Context, db *mongo.Database) (ProductRepository, error) {
+	collection := db.Collection(productCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", productCollection)
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "shop", Value: 1}, {Key: "product_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// Upsert inserts the products with a null deleted_at, only the
+		// tombstones have a date to expire on
+		{
+			Keys: bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().
+				SetExpireAfterSeconds(int32(productTombstoneTTL.Seconds())).
+				SetPartialFilterExpression(bson.M{"deleted_at": bson.M{"$type": "date"}}),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index on %s: %w", productCollection, err)
+	}
+
+	return &productRepository{
+		collection: collection,
+	}, nil
+}
+
+func (r *productRepository) FindByShop(ctx context.Context, shop string) ([]model.Product, error) {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["deleted_at"] = nil
+
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "product_id", Value: 1}}))
+	if err != nil {
+		return []model.Product{}, err
+	}
+
+	var results []model.Product
+	err = cursor.All(ctx, &results)
+	if err != nil {
+		return []model.Product{}, err
+	}
+
+	return results, nil
+}
+
+func (r *productRepository) FindByShopAndProductID(ctx context.Context, shop string, productId int64) (model.Product, error) {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["product_id"] = productId
+	filter["deleted_at"] = nil
+
+	var result model.Product
+	err := r.collection.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.Product{}, nil
+		}
+		return model.Product{}, err
+	}
+
+	return result, nil
+}
+
+func (r *productRepository) Upsert(ctx context.Context, data model.Product) (bool, error) {
+	if data.ShopifyUpdatedAt == nil {
+		return false, errProductUpdatedAtRequired
+	}
+
+	// the filter does not match a more recent or deleted product, the upsert
+	// then fails on the unique index instead of inserting a duplicate. The
+	// updates of the same second are all applied, and the tombstones not
+	// removed yet by the TTL monitor are replaced once expired.
+	now := time.Now().Truncate(time.Millisecond)
+	filter := bson.M{}
+	filter["shop"] = data.Shop
+	filter["product_id"] = data.ProductID
+	filter["$or"] = bson.A{
+		bson.M{
+			"deleted_at": nil,
+			"$or": bson.A{
+				bson.M{"shopify_updated_at": bson.M{"$lte": data.ShopifyUpdatedAt}},
+				bson.M{"shopify_updated_at": nil},
+			},
+		},
+		bson.M{"deleted_at": bson.M{"$lt": now.Add(-productTombstoneTTL)}},
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"title":              data.Title,
+			"body_html":          data.BodyHTML,
+			"vendor":             data.Vendor,
+			"product_type":       data.ProductType,
+			"handle":             data.Handle,
+			"status":             data.Status,
+			"tags":               data.Tags,
+			"variants":           data.Variants,
+			"shopify_updated_at": data.ShopifyUpdatedAt,
+			"updated_at":         now,
+			"deleted_at":         nil,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (r *productRepository) Delete(ctx context.Context, shop string, productId int64) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["product_id"] = productId
+
+	now := time.Now().Truncate(time.Millisecond)
+	update := bson.M{
+		"$set": bson.M{
+			"variants":   bson.A{},
+			"updated_at": now,
+			"deleted_at": now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *productRepository) DeleteByShop(ctx context.Context, shop string) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+
+	_, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	sessionCollection = "shopify_session"
+)
+
+type SessionRepository interface {
+	// FindByShopAndUser returns the session of a staff member of shop, an
+	// empty ShopifySession is returned when it does not exist.
+	FindByShopAndUser(ctx context.Context, shop string, userId int64) (model.ShopifySession, error)
+	// Upsert replaces the session of data.Shop and data.UserID, it is created
+	// when needed.
+	Upsert(ctx context.Context, data model.ShopifySession) (model.ShopifySession, error)
+	// DeleteByShop removes the sessions of every staff member of shop.
+	DeleteByShop(ctx context.Context, shop string) error
+}
+
+type sessionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSessionRepository(ctx context.Context, db *mongo.Database) (SessionRepository, error) {
+	collection := db.Collection(sessionCollection)
+	if collection == nil {
+		return nil, fmt.Errorf("failed to get collection %s", sessionCollection)
+	}
+
+	_, err := collection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "shop", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		// let mongo remove the expired sessions
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index on %s: %w", sessionCollection, err)
+	}
+
+	return &sessionRepository{
+		collection: collection,
+	}, nil
+}
+
+func (r *sessionRepository) FindByShopAndUser(ctx context.Context, shop string, userId int64) (model.ShopifySession, error) {
+	filter := bson.M{}
+	filter["shop"] = shop
+	filter["user_id"] = userId
+
+	var result model.ShopifySession
+	err := r.collection.FindOne(ctx, filter).Decode(&result)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return model.ShopifySession{}, nil
+		}
+		return model.ShopifySession{}, err
+	}
+
+	return result, nil
+}
+
+func (r *sessionRepository) Upsert(ctx context.Context, data model.ShopifySession) (model.ShopifySession, error) {
+	filter := bson.M{}
+	filter["shop"] = data.Shop
+	filter["user_id"] = data.UserID
+
+	now := time.Now().Truncate(time.Millisecond)
+	update := bson.M{
+		"$set": bson.M{
+			"access_token":          data.AccessToken,
+			"access_token_key_id":   data.AccessTokenKeyID,
+			"access_token_data_key": data.AccessTokenDataKey,
+			"scope":                 data.Scope,
+			"associated_user_scope": data.AssociatedUserScope,
+			"associated_user":       data.AssociatedUser,
+			"expires_at":            data.ExpiresAt,
+			"updated_at":            now,
+		},
+		"$setOnInsert": bson.M{
+			"_id":        primitive.NewObjectID(),
+			"created_at": now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().
+		SetUpsert(true).
+		SetReturnDocument(options.After)
+
+	var result model.ShopifySession
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&result)
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	return result, nil
+}
+
+func (r *sessionRepository) DeleteByShop(ctx context.Context, shop string) error {
+	filter := bson.M{}
+	filter["shop"] = shop
+
+	_, err := r.collection.DeleteMany(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
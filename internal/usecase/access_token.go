@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+// accessTokenCheckInterval is how long an access token accepted by Shopify
+// is trusted before being checked again.
+const accessTokenCheckInterval = 5 * time.Minute
+
+type accessTokenCheck struct {
+	accessToken string
+	checkedAt   time.Time
+}
+
+// accessTokenChecks remembers the last access token of each shop accepted
+// by Shopify, so that it is not checked on every request.
+type accessTokenChecks struct {
+	mutex  sync.Mutex
+	checks map[string]accessTokenCheck
+}
+
+func newAccessTokenChecks() *accessTokenChecks {
+	return &accessTokenChecks{
+		checks: map[string]accessTokenCheck{},
+	}
+}
+
+func (c *accessTokenChecks) isRecent(shop string, accessToken string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	check, ok := c.checks[shop]
+	return ok && check.accessToken == accessToken && time.Since(check.checkedAt) < accessTokenCheckInterval
+}
+
+func (c *accessTokenChecks) save(shop string, accessToken string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.checks[shop] = accessTokenCheck{accessToken: accessToken, checkedAt: time.Now()}
+}
+
+func (c *accessTokenChecks) forget(shop string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.checks, shop)
+}
+
+// checkAccessToken reports whether Shopify still accepts the access token
+// stored for the shop. A revoked token means that the app/uninstalled
+// webhook was missed, the shop is then uninstalled so that it goes through
+// the install again instead of keeping the dead token.
+func (uc *shopifyUsecase) checkAccessToken(ctx context.Context, auth model.ShopifyAuth) (bool, error) {
+	if uc.accessTokenChecks.isRecent(auth.Shop, auth.AccessToken) {
+		return true, nil
+	}
+
+	_, err := uc.shopifyClient.ListAccessScopes(ctx, auth.Shop, auth.AccessToken)
+	if shopify.IsUnauthorized(err) {
+		log.Ctx(ctx).Warn().Err(err).Msg("access token revoked, uninstalling the shop")
+		return false, uc.uninstall(ctx, auth.Shop)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	uc.accessTokenChecks.save(auth.Shop, auth.AccessToken)
+
+	return true, nil
+}
+
+// uninstall cancels the work running for the shop and soft-deletes its
+// auth, its access token being revoked by Shopify.
+func (uc *shopifyUsecase) uninstall(ctx context.Context, shop string) error {
+	// the work still running for the shop can only fail
+	uc.jobs.cancel(shop)
+	uc.accessTokenChecks.forget(shop)
+
+	err := uc.authRepository.SoftDelete(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	return uc.sessionRepository.DeleteByShop(ctx, shop)
+}
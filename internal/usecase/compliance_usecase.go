@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/repository"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	customersDataRequestTopic webhookTopic = "customers/data_request"
+	customersRedactTopic      webhookTopic = "customers/redact"
+	shopRedactTopic           webhookTopic = "shop/redact"
+)
+
+// ComplianceUsecase handles the mandatory privacy webhooks of Shopify. Every
+// request is recorded before being processed so that it can be audited. The
+// records only hold identifiers, never the personal data of the customer.
+type ComplianceUsecase interface {
+	HandleCustomersDataRequest(ctx context.Context, req WebhookRequest) error
+	HandleCustomersRedact(ctx context.Context, req WebhookRequest) error
+	HandleShopRedact(ctx context.Context, req WebhookRequest) error
+}
+
+type complianceUsecase struct {
+	authRepository            repository.AuthRepository
+	complianceRepository      repository.ComplianceRepository
+	sessionRepository         repository.SessionRepository
+	productRepository         repository.ProductRepository
+	productBackfillRepository repository.ProductBackfillRepository
+}
+
+func NewComplianceUsecase(
+	authRepository repository.AuthRepository,
+	complianceRepository repository.ComplianceRepository,
+	sessionRepository repository.SessionRepository,
+	productRepository repository.ProductRepository,
+	productBackfillRepository repository.ProductBackfillRepository,
+) (ComplianceUsecase, error) {
+	return &complianceUsecase{
+		authRepository:            authRepository,
+		complianceRepository:      complianceRepository,
+		sessionRepository:         sessionRepository,
+		productRepository:         productRepository,
+		productBackfillRepository: productBackfillRepository,
+	}, nil
+}
+
+func (uc *complianceUsecase) HandleCustomersDataRequest(ctx context.Context, req WebhookRequest) error {
+	var payload shopify.CustomersDataRequestPayload
+	return uc.handle(ctx, req, customersDataRequestTopic, &payload, func(ctx context.Context, request *model.ComplianceRequest) error {
+		request.CustomerID = payload.Customer.ID
+
+		// the app does not store any customer data, the bundle only
+		// acknowledges the request
+		now := time.Now()
+		request.Export = &model.CustomerDataExport{
+			Shop:            payload.ShopDomain,
+			CustomerID:      payload.Customer.ID,
+			OrdersRequested: payload.OrdersRequested,
+			Data:            map[string]interface{}{},
+			GeneratedAt:     &now,
+		}
+
+		return nil
+	})
+}
+
+func (uc *complianceUsecase) HandleCustomersRedact(ctx context.Context, req WebhookRequest) error {
+	var payload shopify.CustomersRedactPayload
+	return uc.handle(ctx, req, customersRedactTopic, &payload, func(ctx context.Context, request *model.ComplianceRequest) error {
+		request.CustomerID = payload.Customer.ID
+
+		// the app does not store any customer data, nothing to redact
+		return nil
+	})
+}
+
+func (uc *complianceUsecase) HandleShopRedact(ctx context.Context, req WebhookRequest) error {
+	var payload shopify.ShopRedactPayload
+	return uc.handle(ctx, req, shopRedactTopic, &payload, func(ctx context.Context, request *model.ComplianceRequest) error {
+		if err := uc.sessionRepository.DeleteByShop(ctx, req.GetShop()); err != nil {
+			return err
+		}
+
+		if err := uc.productRepository.DeleteByShop(ctx, req.GetShop()); err != nil {
+			return err
+		}
+
+		if err := uc.productBackfillRepository.DeleteByShop(ctx, req.GetShop()); err != nil {
+			return err
+		}
+
+		return uc.authRepository.HardDelete(ctx, req.GetShop())
+	})
+}
+
+// handle records the request, decodes its payload and runs process. The
+// outcome is saved on the record whether process succeeded or not. Webhooks
+// of another topic than the one of the route are rejected.
+func (uc *complianceUsecase) handle(
+	ctx context.Context,
+	req WebhookRequest,
+	topic webhookTopic,
+	payload interface{},
+	process func(ctx context.Context, request *model.ComplianceRequest) error,
+) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	if req.GetTopic() != string(topic) {
+		return fmt.Errorf("%w: %q webhook received for %q", ErrInvalidWebhook, req.GetTopic(), topic)
+	}
+
+	logger := log.Ctx(ctx).With().
+		Str("topic", req.GetTopic()).
+		Str("shop", req.GetShop()).
+		Str("webhook_id", req.GetWebhookId()).
+		Logger()
+
+	request, err := uc.complianceRepository.Save(ctx, model.ComplianceRequest{
+		Shop:      req.GetShop(),
+		Topic:     req.GetTopic(),
+		WebhookID: req.GetWebhookId(),
+		Status:    model.ComplianceStatusReceived,
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to record compliance request")
+		return err
+	}
+
+	err = req.decode(payload)
+	if err == nil {
+		err = process(ctx, &request)
+	}
+
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to process compliance request")
+		request.Status = model.ComplianceStatusFailed
+		request.Error = err.Error()
+	} else {
+		now := time.Now()
+		request.Status = model.ComplianceStatusCompleted
+		request.CompletedAt = &now
+	}
+
+	_, updateErr := uc.complianceRepository.Update(ctx, request)
+	if updateErr != nil {
+		logger.Error().Err(updateErr).Msg("failed to update compliance request")
+		if err == nil {
+			err = updateErr
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,185 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/repository"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const testShop = "shop.myshopify.com"
+
+// recordingComplianceRepository records every update of the compliance
+// requests, the repositories have no way to list them.
+type recordingComplianceRepository struct {
+	repository.ComplianceRepository
+	requests []model.ComplianceRequest
+}
+
+func (r *recordingComplianceRepository) Update(ctx context.Context, data model.ComplianceRequest) (model.ComplianceRequest, error) {
+	r.requests = append(r.requests, data)
+	return r.ComplianceRepository.Update(ctx, data)
+}
+
+type complianceTest struct {
+	usecase    ComplianceUsecase
+	compliance *recordingComplianceRepository
+	auth       repository.AuthRepository
+	session    repository.SessionRepository
+	product    repository.ProductRepository
+	backfill   repository.ProductBackfillRepository
+}
+
+func newComplianceTest(t *testing.T) *complianceTest {
+	t.Helper()
+
+	ct := &complianceTest{
+		compliance: &recordingComplianceRepository{ComplianceRepository: repository.NewMemoryComplianceRepository()},
+		auth:       repository.NewMemoryAuthRepository(),
+		session:    repository.NewMemorySessionRepository(),
+		product:    repository.NewMemoryProductRepository(),
+		backfill:   repository.NewMemoryProductBackfillRepository(),
+	}
+
+	uc, err := NewComplianceUsecase(ct.auth, ct.compliance, ct.session, ct.product, ct.backfill)
+	if err != nil {
+		t.Fatalf("NewComplianceUsecase() error = %v", err)
+	}
+	ct.usecase = uc
+
+	return ct
+}
+
+func newWebhookRequest(t *testing.T, topic string, payload interface{}) WebhookRequest {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+
+	header := http.Header{}
+	header.Set(shopify.WebhookTopicHeader, topic)
+	header.Set(shopify.WebhookShopDomainHeader, testShop)
+	header.Set(shopify.WebhookIdHeader, "webhook-1")
+
+	return WebhookRequest{Header: header, Body: body}
+}
+
+func TestComplianceCustomersDataRequest(t *testing.T) {
+	ct := newComplianceTest(t)
+
+	payload := shopify.CustomersDataRequestPayload{
+		ShopDomain:      testShop,
+		OrdersRequested: []int64{11, 12},
+		Customer:        shopify.ComplianceCustomer{ID: 7, Email: "jane@example.com", Phone: "+81-90-0000-0000"},
+	}
+	if err := ct.usecase.HandleCustomersDataRequest(context.Background(), newWebhookRequest(t, "customers/data_request", payload)); err != nil {
+		t.Fatalf("HandleCustomersDataRequest() error = %v", err)
+	}
+
+	request := ct.compliance.requests[len(ct.compliance.requests)-1]
+	if request.Status != model.ComplianceStatusCompleted || request.CustomerID != 7 || request.WebhookID != "webhook-1" {
+		t.Errorf("compliance request = %+v, want a completed request of customer 7", request)
+	}
+	if request.Export == nil || request.Export.CustomerID != 7 || len(request.Export.OrdersRequested) != 2 {
+		t.Errorf("compliance request export = %+v, want the export of customer 7", request.Export)
+	}
+	assertNoPII(t, request)
+}
+
+func TestComplianceCustomersRedact(t *testing.T) {
+	ct := newComplianceTest(t)
+
+	payload := shopify.CustomersRedactPayload{
+		ShopDomain:     testShop,
+		Customer:       shopify.ComplianceCustomer{ID: 7, Email: "jane@example.com", Phone: "+81-90-0000-0000"},
+		OrdersToRedact: []int64{11, 12},
+	}
+	if err := ct.usecase.HandleCustomersRedact(context.Background(), newWebhookRequest(t, "customers/redact", payload)); err != nil {
+		t.Fatalf("HandleCustomersRedact() error = %v", err)
+	}
+
+	request := ct.compliance.requests[len(ct.compliance.requests)-1]
+	if request.Status != model.ComplianceStatusCompleted || request.CustomerID != 7 {
+		t.Errorf("compliance request = %+v, want a completed request of customer 7", request)
+	}
+	assertNoPII(t, request)
+}
+
+func TestComplianceShopRedact(t *testing.T) {
+	ct := newComplianceTest(t)
+	ctx := context.Background()
+
+	if _, err := ct.auth.Upsert(ctx, model.ShopifyAuth{Shop: testShop, AccessToken: "token"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if err := ct.auth.SoftDelete(ctx, testShop); err != nil {
+		t.Fatalf("SoftDelete() error = %v", err)
+	}
+	if _, err := ct.session.Upsert(ctx, model.ShopifySession{Shop: testShop, UserID: 1, AccessToken: "token"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	updatedAt := time.Now()
+	if _, err := ct.product.Upsert(ctx, model.Product{Shop: testShop, ProductID: 1, ShopifyUpdatedAt: &updatedAt}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	if _, err := ct.backfill.Upsert(ctx, model.ProductBackfill{Shop: testShop, Status: model.ProductBackfillCompleted}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	payload := shopify.ShopRedactPayload{ShopDomain: testShop}
+	if err := ct.usecase.HandleShopRedact(ctx, newWebhookRequest(t, "shop/redact", payload)); err != nil {
+		t.Fatalf("HandleShopRedact() error = %v", err)
+	}
+
+	if auths, _ := ct.auth.FindAll(ctx); len(auths) != 0 {
+		t.Errorf("auths after shop/redact = %+v, want none", auths)
+	}
+	if session, _ := ct.session.FindByShopAndUser(ctx, testShop, 1); !session.IsEmpty() {
+		t.Errorf("session after shop/redact = %+v, want none", session)
+	}
+	if products, _ := ct.product.FindByShop(ctx, testShop); len(products) != 0 {
+		t.Errorf("products after shop/redact = %+v, want none", products)
+	}
+	if backfill, _ := ct.backfill.FindByShop(ctx, testShop); !backfill.IsEmpty() {
+		t.Errorf("backfill after shop/redact = %+v, want none", backfill)
+	}
+
+	request := ct.compliance.requests[len(ct.compliance.requests)-1]
+	if request.Status != model.ComplianceStatusCompleted {
+		t.Errorf("compliance request = %+v, want a completed request", request)
+	}
+}
+
+func TestComplianceTopicMismatch(t *testing.T) {
+	ct := newComplianceTest(t)
+
+	// a customers/redact delivered to the shop/redact route must not purge
+	// the shop
+	req := newWebhookRequest(t, "customers/redact", shopify.CustomersRedactPayload{ShopDomain: testShop})
+	if err := ct.usecase.HandleShopRedact(context.Background(), req); !errors.Is(err, ErrInvalidWebhook) {
+		t.Errorf("HandleShopRedact() error = %v, want ErrInvalidWebhook", err)
+	}
+	if len(ct.compliance.requests) != 0 {
+		t.Errorf("compliance requests = %+v, want none", ct.compliance.requests)
+	}
+}
+
+func assertNoPII(t *testing.T, request model.ComplianceRequest) {
+	t.Helper()
+
+	encoded, _ := json.Marshal(request)
+	for _, pii := range []string{"jane@example.com", "+81-90-0000-0000"} {
+		if strings.Contains(string(encoded), pii) {
+			t.Errorf("compliance request %s contains %q", encoded, pii)
+		}
+	}
+}
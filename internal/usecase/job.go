@@ -0,0 +1,108 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+)
+
+type job struct {
+	cancel context.CancelFunc
+	// done is closed once the job returned.
+	done chan struct{}
+}
+
+// jobRegistry keeps track of the background work started for each shop, so
+// that it can be cancelled when the shop uninstalls the app.
+type jobRegistry struct {
+	mutex sync.Mutex
+	jobs  map[string]map[string]*job
+	// cancelled holds the cancelled jobs until they return, a job started
+	// again waits for them so that their last writes come first.
+	cancelled map[string]map[string]*job
+}
+
+func newJobRegistry() *jobRegistry {
+	return &jobRegistry{
+		jobs:      map[string]map[string]*job{},
+		cancelled: map[string]map[string]*job{},
+	}
+}
+
+// start runs fn in the background unless a job with the same name is already
+// running for shop, and reports whether it was started. fn only runs once
+// the cancelled job with the same name returned.
+func (r *jobRegistry) start(shop string, name string, fn func(ctx context.Context)) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.jobs[shop][name]; ok {
+		return false
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	ctx = log.With().Str("shop", shop).Str("job", name).Logger().WithContext(ctx)
+
+	j := &job{cancel: cancel, done: make(chan struct{})}
+	addJob(r.jobs, shop, name, j)
+	previous := r.cancelled[shop][name]
+
+	go func() {
+		defer r.remove(shop, name, j)
+		defer close(j.done)
+		defer cancel()
+
+		if previous != nil {
+			// waited even when cancelled, as the next job only waits for
+			// this one
+			<-previous.done
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		fn(ctx)
+	}()
+
+	return true
+}
+
+func (r *jobRegistry) remove(shop string, name string, j *job) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.jobs[shop][name] == j {
+		deleteJob(r.jobs, shop, name)
+	}
+	if r.cancelled[shop][name] == j {
+		deleteJob(r.cancelled, shop, name)
+	}
+}
+
+// cancel stops every job running for shop.
+func (r *jobRegistry) cancel(shop string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for name, j := range r.jobs[shop] {
+		log.Info().Str("shop", shop).Str("job", name).Msg("cancel job")
+		j.cancel()
+		addJob(r.cancelled, shop, name, j)
+	}
+	delete(r.jobs, shop)
+}
+
+func addJob(jobs map[string]map[string]*job, shop string, name string, j *job) {
+	if jobs[shop] == nil {
+		jobs[shop] = map[string]*job{}
+	}
+	jobs[shop][name] = j
+}
+
+func deleteJob(jobs map[string]map[string]*job, shop string, name string) {
+	delete(jobs[shop], name)
+	if len(jobs[shop]) == 0 {
+		delete(jobs, shop)
+	}
+}
@@ -0,0 +1,86 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	bulkOperationPollInterval = 5 * time.Second
+)
+
+type ExportCatalogRequest struct {
+	Shop string
+	// Handler is called with every exported product, in the order of the
+	// bulk operation result.
+	Handler func(ctx context.Context, product shopify.Product) error
+}
+
+func (r *ExportCatalogRequest) Validate() error {
+	if r.Shop == "" {
+		return errors.New(`missing "shop" parameter`)
+	}
+
+	if r.Handler == nil {
+		return errors.New(`missing "handler" parameter`)
+	}
+
+	return nil
+}
+
+// ExportCatalog exports every product of the shop with a bulk operation and
+// streams the result to req.Handler without loading the whole file.
+func (uc *shopifyUsecase) ExportCatalog(ctx context.Context, req ExportCatalogRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	ctx = log.Ctx(ctx).With().Str("shop", req.Shop).Logger().WithContext(ctx)
+
+	auth, err := uc.authRepository.FindByShop(ctx, req.Shop)
+	if err != nil {
+		return err
+	}
+
+	if auth.IsEmpty() {
+		return errors.New("shop is not installed")
+	}
+
+	operation, err := uc.shopifyClient.RunBulkQuery(ctx, auth.Shop, auth.AccessToken, shopify.BulkProductsQuery)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to run bulk query")
+		return err
+	}
+
+	operation, err = uc.shopifyClient.WaitBulkOperation(ctx, auth.Shop, auth.AccessToken, operation.ID, bulkOperationPollInterval)
+	if err != nil {
+		return err
+	}
+
+	if operation.Status != shopify.BulkOperationCompleted {
+		return errors.New("bulk operation " + operation.ID + " is " + operation.Status + " " + operation.ErrorCode)
+	}
+
+	log.Ctx(ctx).Info().
+		Str("object_count", operation.ObjectCount).
+		Msg("bulk operation completed")
+
+	body, err := uc.shopifyClient.DownloadBulkOperation(ctx, operation)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	return shopify.DecodeBulkProducts(body, func(bulkProduct shopify.BulkProduct) error {
+		product, err := bulkProduct.ToProduct()
+		if err != nil {
+			return err
+		}
+
+		return req.Handler(ctx, product)
+	})
+}
@@ -0,0 +1,59 @@
+package usecase
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+// syncProduct stores product in the local mirror of the catalog of shop,
+// unless a more recent version is already stored.
+func (uc *shopifyUsecase) syncProduct(ctx context.Context, shop string, product shopify.Product) error {
+	stored, err := uc.productRepository.Upsert(ctx, newProduct(shop, product))
+	if err != nil {
+		return err
+	}
+
+	if !stored {
+		log.Ctx(ctx).Info().Int64("product_id", product.ID).Msg("outdated or deleted product ignored")
+	}
+
+	return nil
+}
+
+func newProduct(shop string, product shopify.Product) model.Product {
+	variants := make([]model.ProductVariant, 0, len(product.Variants))
+	for _, variant := range product.Variants {
+		compareAtPrice := ""
+		if variant.CompareAtPrice != nil {
+			compareAtPrice = *variant.CompareAtPrice
+		}
+
+		variants = append(variants, model.ProductVariant{
+			VariantID:         variant.ID,
+			Title:             variant.Title,
+			Sku:               variant.Sku,
+			Barcode:           variant.Barcode,
+			Price:             variant.Price,
+			CompareAtPrice:    compareAtPrice,
+			Position:          variant.Position,
+			InventoryQuantity: variant.InventoryQuantity,
+		})
+	}
+
+	return model.Product{
+		Shop:             shop,
+		ProductID:        product.ID,
+		Title:            product.Title,
+		BodyHTML:         product.BodyHTML,
+		Vendor:           product.Vendor,
+		ProductType:      product.ProductType,
+		Handle:           product.Handle,
+		Status:           product.Status,
+		Tags:             product.Tags,
+		Variants:         variants,
+		ShopifyUpdatedAt: product.UpdatedAt,
+	}
+}
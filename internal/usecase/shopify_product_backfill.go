@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	productBackfillJob      = "product_backfill"
+	productBackfillPageSize = 250
+)
+
+func (uc *shopifyUsecase) GetProductBackfill(ctx context.Context, shop string) (model.ProductBackfill, error) {
+	return uc.productBackfillRepository.FindByShop(ctx, shop)
+}
+
+func (uc *shopifyUsecase) ResumeProductBackfills(ctx context.Context) error {
+	backfills, err := uc.productBackfillRepository.FindRunning(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, backfill := range backfills {
+		backfill := backfill
+		log.Ctx(ctx).Info().
+			Str("shop", backfill.Shop).
+			Int64("since_id", backfill.SinceID).
+			Msg("resume product backfill")
+		uc.jobs.start(backfill.Shop, productBackfillJob, func(ctx context.Context) {
+			uc.runProductBackfill(ctx, backfill)
+		})
+	}
+
+	return nil
+}
+
+// startProductBackfill copies every product of a newly installed shop in
+// the background, replacing the progress of a previous install. Nothing is
+// replaced while a backfill of the shop is still running.
+func (uc *shopifyUsecase) startProductBackfill(ctx context.Context, shop string) {
+	started := uc.jobs.start(shop, productBackfillJob, func(ctx context.Context) {
+		now := time.Now()
+		backfill, err := uc.productBackfillRepository.Upsert(ctx, model.ProductBackfill{
+			Shop:      shop,
+			Status:    model.ProductBackfillRunning,
+			StartedAt: &now,
+		})
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("failed to start product backfill")
+			return
+		}
+
+		uc.runProductBackfill(ctx, backfill)
+	})
+	if !started {
+		log.Ctx(ctx).Info().Msg("product backfill already running")
+	}
+}
+
+func (uc *shopifyUsecase) runProductBackfill(ctx context.Context, backfill model.ProductBackfill) {
+	err := uc.backfillProducts(ctx, &backfill)
+
+	now := time.Now()
+	backfill.FinishedAt = &now
+	switch {
+	case ctx.Err() != nil:
+		// the job was cancelled by an uninstall, its context can no longer
+		// be used to save the status
+		ctx = log.Ctx(ctx).WithContext(context.Background())
+		backfill.Status = model.ProductBackfillCancelled
+		log.Ctx(ctx).Info().Int("synced_count", backfill.SyncedCount).Msg("product backfill cancelled")
+	case err != nil:
+		backfill.Status = model.ProductBackfillFailed
+		backfill.Error = err.Error()
+		log.Ctx(ctx).Error().Err(err).Int("synced_count", backfill.SyncedCount).Msg("product backfill failed")
+	default:
+		backfill.Status = model.ProductBackfillCompleted
+		log.Ctx(ctx).Info().Int("synced_count", backfill.SyncedCount).Msg("product backfill completed")
+	}
+
+	_, err = uc.productBackfillRepository.Upsert(ctx, backfill)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to save product backfill")
+	}
+}
+
+// backfillProducts stores the products of the shop page by page, after
+// backfill.SinceID, saving the progress after every page.
+func (uc *shopifyUsecase) backfillProducts(ctx context.Context, backfill *model.ProductBackfill) error {
+	auth, err := uc.authRepository.FindByShop(ctx, backfill.Shop)
+	if err != nil {
+		return err
+	}
+
+	if auth.IsEmpty() || auth.AccessToken == "" {
+		return errors.New("shop is not installed")
+	}
+
+	if backfill.SinceID == 0 {
+		backfill.ProductCount, err = uc.shopifyClient.CountProduct(ctx, auth.Shop, auth.AccessToken, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		options := &shopify.ProductOptions{
+			ListOptions: shopify.ListOptions{
+				Limit:   productBackfillPageSize,
+				SinceID: backfill.SinceID,
+			},
+		}
+		products, _, err := uc.shopifyClient.ListProductWithPagination(ctx, auth.Shop, auth.AccessToken, options)
+		if err != nil {
+			return err
+		}
+
+		if len(products) == 0 {
+			return nil
+		}
+
+		for _, product := range products {
+			if err := uc.syncProduct(ctx, auth.Shop, product); err != nil {
+				return err
+			}
+		}
+
+		backfill.SinceID = products[len(products)-1].ID
+		backfill.SyncedCount += len(products)
+
+		_, err = uc.productBackfillRepository.Upsert(ctx, *backfill)
+		if err != nil {
+			return err
+		}
+
+		if len(products) < productBackfillPageSize {
+			return nil
+		}
+	}
+}
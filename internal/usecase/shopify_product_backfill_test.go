@@ -0,0 +1,176 @@
+package usecase
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/repository"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+// blockingProductRepository holds the first product stored until released,
+// so that the backfill can be cancelled in the middle of a page.
+type blockingProductRepository struct {
+	repository.ProductRepository
+	first    atomic.Bool
+	blocked  chan struct{}
+	released chan struct{}
+}
+
+func newBlockingProductRepository() *blockingProductRepository {
+	return &blockingProductRepository{
+		ProductRepository: repository.NewMemoryProductRepository(),
+		blocked:           make(chan struct{}),
+		released:          make(chan struct{}),
+	}
+}
+
+func (r *blockingProductRepository) Upsert(ctx context.Context, data model.Product) (bool, error) {
+	// later products are not held, e.g. the ones of the next backfill
+	if r.first.CompareAndSwap(false, true) {
+		close(r.blocked)
+		<-r.released
+	}
+	return r.ProductRepository.Upsert(ctx, data)
+}
+
+// waitProductBackfill waits for the backfill of the shop to reach status.
+func (st *shopifyTest) waitProductBackfill(t *testing.T, status string) model.ProductBackfill {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		backfill, err := st.backfill.FindByShop(context.Background(), st.server.Shop)
+		if err != nil {
+			t.Fatalf("FindByShop() error = %v", err)
+		}
+		if backfill.Status == status {
+			return backfill
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("product backfill = %+v, want %s", backfill, status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestProductBackfillPages(t *testing.T) {
+	st := newShopifyTest(t)
+
+	const productCount = productBackfillPageSize + 10
+	for i := 0; i < productCount; i++ {
+		st.server.AddProduct(shopify.Product{Title: "product"})
+	}
+
+	st.usecase.startProductBackfill(context.Background(), st.server.Shop)
+	backfill := st.waitProductBackfill(t, model.ProductBackfillCompleted)
+
+	products := st.server.Products()
+	if backfill.ProductCount != productCount || backfill.SyncedCount != productCount || backfill.SinceID != products[len(products)-1].ID {
+		t.Errorf("product backfill = %+v, want %d products synced", backfill, productCount)
+	}
+
+	stored, err := st.usecase.productRepository.FindByShop(context.Background(), st.server.Shop)
+	if err != nil {
+		t.Fatalf("FindByShop() error = %v", err)
+	}
+	if len(stored) != productCount {
+		t.Errorf("stored products = %d, want %d", len(stored), productCount)
+	}
+
+	pages := 0
+	for _, r := range st.server.Requests() {
+		if r.URL.Path == "/admin/api/"+shopify.DefaultAPIVersion+"/products.json" {
+			pages++
+		}
+	}
+	if pages != 2 {
+		t.Errorf("pages requested = %d, want 2", pages)
+	}
+}
+
+func TestProductBackfillCancelled(t *testing.T) {
+	st := newShopifyTest(t)
+	products := newBlockingProductRepository()
+	st.usecase.productRepository = products
+	st.server.AddProduct(shopify.Product{Title: "product"})
+
+	st.usecase.startProductBackfill(context.Background(), st.server.Shop)
+	<-products.blocked
+
+	st.usecase.jobs.cancel(st.server.Shop)
+	close(products.released)
+
+	backfill := st.waitProductBackfill(t, model.ProductBackfillCancelled)
+	if backfill.FinishedAt == nil {
+		t.Errorf("product backfill = %+v, want it finished", backfill)
+	}
+}
+
+func TestProductBackfillRestartedAfterCancel(t *testing.T) {
+	st := newShopifyTest(t)
+	products := newBlockingProductRepository()
+	backfills := &recordingProductBackfillRepository{ProductBackfillRepository: st.backfill}
+	st.usecase.productRepository = products
+	st.usecase.productBackfillRepository = backfills
+	st.server.AddProduct(shopify.Product{Title: "product"})
+
+	st.usecase.startProductBackfill(context.Background(), st.server.Shop)
+	<-products.blocked
+
+	// the shop reinstalls while the cancelled backfill is still running,
+	// whose cancelled status must not replace the new backfill
+	st.usecase.jobs.cancel(st.server.Shop)
+	st.usecase.startProductBackfill(context.Background(), st.server.Shop)
+	// leave the new backfill the time to complete if it does not wait
+	time.Sleep(100 * time.Millisecond)
+	close(products.released)
+
+	var statuses []string
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		statuses = backfills.finished()
+		if len(statuses) == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("finished product backfills = %v, want 2", statuses)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if statuses[0] != model.ProductBackfillCancelled || statuses[1] != model.ProductBackfillCompleted {
+		t.Errorf("finished product backfills = %v, want the cancelled one first", statuses)
+	}
+	if backfill := st.waitProductBackfill(t, model.ProductBackfillCompleted); backfill.SyncedCount != 1 {
+		t.Errorf("product backfill = %+v, want 1 product synced", backfill)
+	}
+}
+
+// recordingProductBackfillRepository records the status of every finished
+// backfill in the order they were saved.
+type recordingProductBackfillRepository struct {
+	repository.ProductBackfillRepository
+	mutex    sync.Mutex
+	statuses []string
+}
+
+func (r *recordingProductBackfillRepository) Upsert(ctx context.Context, data model.ProductBackfill) (model.ProductBackfill, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if data.FinishedAt != nil {
+		r.statuses = append(r.statuses, data.Status)
+	}
+	return r.ProductBackfillRepository.Upsert(ctx, data)
+}
+
+func (r *recordingProductBackfillRepository) finished() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]string(nil), r.statuses...)
+}
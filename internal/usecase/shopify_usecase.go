@@ -3,8 +3,11 @@ package usecase
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/url"
+	"strconv"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
@@ -15,7 +18,12 @@ import (
 )
 
 const (
-	scopes = "read_products,write_products"
+	scopes   = "read_products,write_products"
+	nonceTTL = 10 * time.Minute
+	// sessionExpiryMargin renews online access tokens a bit before they
+	// expire, so that they do not expire in the middle of a request
+	sessionExpiryMargin = time.Minute
+	onlineAccessMode    = "online"
 )
 
 type webhookTopic string
@@ -27,22 +35,58 @@ const (
 	appUninstalledTopic webhookTopic = "app/uninstalled"
 )
 
+// webhookTopics are the topics every installed shop is subscribed to.
+var webhookTopics = []webhookTopic{
+	productCreatedTopic,
+	productUpdatedTopic,
+	productDeletedTopic,
+	appUninstalledTopic,
+}
+
 type ShopifyUsecase interface {
 	RequestAuthorization(ctx context.Context, req RequestAuthorizationRequest) (string, error)
 	Authorize(ctx context.Context, req AuthorizeRequest) error
+	HandleWebhook(ctx context.Context, req WebhookRequest) error
+	ExportCatalog(ctx context.Context, req ExportCatalogRequest) error
+	AuthorizeSession(ctx context.Context, session *shopify.SessionToken) error
+	// AuthorizeUser returns the online session of the user of a session
+	// token, it is renewed with a token exchange when missing or expired.
+	AuthorizeUser(ctx context.Context, session *shopify.SessionToken) (model.ShopifySession, error)
+	// GetProductBackfill returns the progress of the copy of the products
+	// made after the install of shop.
+	GetProductBackfill(ctx context.Context, shop string) (model.ProductBackfill, error)
+	// ResumeProductBackfills restarts the backfills interrupted by a restart
+	// of the app.
+	ResumeProductBackfills(ctx context.Context) error
+	// ReconcileWebhooks repairs the webhook subscriptions of every installed
+	// shop.
+	ReconcileWebhooks(ctx context.Context) error
 }
 
+// ErrUnauthorized is returned when Shopify refuses to authorize a session.
+var ErrUnauthorized = errors.New("unauthorized")
+
 type shopifyUsecase struct {
-	shopifyClient  shopify.Client
-	authRepository repository.AuthRepository
-	apiKey         string
-	apiSecret      string
-	serverUrl      string
+	shopifyClient             shopify.Client
+	authRepository            repository.AuthRepository
+	nonceRepository           repository.NonceRepository
+	sessionRepository         repository.SessionRepository
+	productRepository         repository.ProductRepository
+	productBackfillRepository repository.ProductBackfillRepository
+	apiKey                    string
+	apiSecret                 string
+	serverUrl                 string
+	jobs                      *jobRegistry
+	accessTokenChecks         *accessTokenChecks
 }
 
 func NewShopifyUsecase(
 	shopifyClient shopify.Client,
 	authRepository repository.AuthRepository,
+	nonceRepository repository.NonceRepository,
+	sessionRepository repository.SessionRepository,
+	productRepository repository.ProductRepository,
+	productBackfillRepository repository.ProductBackfillRepository,
 ) (ShopifyUsecase, error) {
 	apiSecret, err := config.MustGet("SHOPIFY_CLIENT_SECRET")
 	if err != nil {
@@ -60,11 +104,17 @@ func NewShopifyUsecase(
 	}
 
 	return &shopifyUsecase{
-		shopifyClient:  shopifyClient,
-		authRepository: authRepository,
-		apiSecret:      apiSecret,
-		apiKey:         apiKey,
-		serverUrl:      serverUrl,
+		shopifyClient:             shopifyClient,
+		authRepository:            authRepository,
+		nonceRepository:           nonceRepository,
+		sessionRepository:         sessionRepository,
+		productRepository:         productRepository,
+		productBackfillRepository: productBackfillRepository,
+		apiSecret:                 apiSecret,
+		apiKey:                    apiKey,
+		serverUrl:                 serverUrl,
+		jobs:                      newJobRegistry(),
+		accessTokenChecks:         newAccessTokenChecks(),
 	}, nil
 }
 
@@ -77,6 +127,14 @@ func (r *RequestAuthorizationRequest) GetShop() string {
 	return val.Get("shop")
 }
 
+// IsOnline reports whether an online access token of the current user is
+// requested, with access_mode=online, instead of the offline token of the
+// shop.
+func (r *RequestAuthorizationRequest) IsOnline() bool {
+	val := r.Url.Query()
+	return val.Get("access_mode") == onlineAccessMode
+}
+
 func (r *RequestAuthorizationRequest) Validate() error {
 	val := r.Url.Query()
 	shop := val.Get("shop")
@@ -84,6 +142,10 @@ func (r *RequestAuthorizationRequest) Validate() error {
 		return errors.New(`missing "shop" parameter`)
 	}
 
+	if !shopify.IsValidShopDomain(shop) {
+		return errors.New(`invalid "shop" parameter`)
+	}
+
 	return nil
 }
 
@@ -92,7 +154,43 @@ func (uc *shopifyUsecase) RequestAuthorization(ctx context.Context, req RequestA
 		return "", err
 	}
 
-	nonce := uuid.NewString()
+	auth, err := uc.authRepository.FindByShop(ctx, req.GetShop())
+	if err != nil {
+		return "", err
+	}
+
+	installed := !auth.IsEmpty() && auth.HasScopes(scopes)
+	if installed {
+		// a revoked token uninstalls the shop, which then goes through OAuth
+		installed, err = uc.checkAccessToken(ctx, auth)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	// already installed with every scope, no need to go through OAuth
+	// unless the configured scopes were upgraded since. Online tokens can
+	// only be requested once the shop is installed.
+	if installed && !req.IsOnline() {
+		appUrl, err := url.Parse(uc.serverUrl + "/app")
+		if err != nil {
+			return "", err
+		}
+		appUrl.RawQuery = req.Url.RawQuery
+
+		return appUrl.String(), nil
+	}
+
+	expiresAt := time.Now().Add(nonceTTL)
+	nonce, err := uc.nonceRepository.Save(ctx, model.OauthNonce{
+		Shop:      req.GetShop(),
+		Nonce:     uuid.NewString(),
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		return "", err
+	}
+
 	redirectedUrl := uc.serverUrl + "/shopify/callback"
 	shopUrl, err := url.Parse("https://" + req.GetShop())
 	if err != nil {
@@ -102,8 +200,11 @@ func (uc *shopifyUsecase) RequestAuthorization(ctx context.Context, req RequestA
 	query := shopUrl.Query()
 	query.Set("client_id", uc.apiKey)
 	query.Set("scope", scopes)
-	query.Set("state", nonce)
+	query.Set("state", nonce.Nonce)
 	query.Set("redirect_uri", redirectedUrl)
+	if installed && req.IsOnline() {
+		query.Set("grant_options[]", "per-user")
+	}
 	shopUrl.RawQuery = query.Encode()
 
 	return shopUrl.String(), nil
@@ -123,6 +224,11 @@ func (r *AuthorizeRequest) GetCode() string {
 	return val.Get("code")
 }
 
+func (r *AuthorizeRequest) GetState() string {
+	val := r.Url.Query()
+	return val.Get("state")
+}
+
 func (r *AuthorizeRequest) Validate(apiSecret string) error {
 	val := r.Url.Query()
 	shop := val.Get("shop")
@@ -130,11 +236,20 @@ func (r *AuthorizeRequest) Validate(apiSecret string) error {
 		return errors.New(`missing "shop" parameter`)
 	}
 
+	if !shopify.IsValidShopDomain(shop) {
+		return errors.New(`invalid "shop" parameter`)
+	}
+
 	code := val.Get("code")
 	if code == "" {
 		return errors.New(`missing "code" parameter`)
 	}
 
+	state := val.Get("state")
+	if state == "" {
+		return errors.New(`missing "state" parameter`)
+	}
+
 	if ok, err := shopify.VerifyAuthUrl(r.Url, apiSecret); !ok || err != nil {
 		return errors.New("hmac is not match")
 	}
@@ -147,83 +262,222 @@ func (uc *shopifyUsecase) Authorize(ctx context.Context, req AuthorizeRequest) e
 		return err
 	}
 
+	ctx = log.Ctx(ctx).With().Str("shop", req.GetShop()).Logger().WithContext(ctx)
+
+	nonce, err := uc.nonceRepository.Consume(ctx, req.GetShop(), req.GetState())
+	if err != nil {
+		return err
+	}
+
+	if nonce.IsEmpty() || nonce.IsExpired() {
+		return errors.New(`invalid "state" parameter`)
+	}
+
 	auth, err := uc.authRepository.FindByShop(ctx, req.GetShop())
 	if err != nil {
 		return err
 	}
 
-	if !auth.IsEmpty() {
-		webhooks, err := uc.shopifyClient.ListWebhook(auth.Shop, auth.AccessToken, nil)
+	// the code is always exchanged so that a reinstall or a scope upgrade
+	// replaces the stored token
+	token, err := uc.shopifyClient.GetAccessToken(ctx, req.GetShop(), req.GetCode())
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed get access token")
+		return err
+	}
+
+	// requested with grant_options[]=per-user
+	if token.AssociatedUser != nil {
+		if auth.IsEmpty() {
+			return errors.New("online access token requested before installation")
+		}
+
+		session, err := uc.sessionRepository.Upsert(ctx, newShopifySession(req.GetShop(), token))
 		if err != nil {
-			log.Error().Err(err).Msg("failed to get ListWebhook")
 			return err
 		}
-		log.Info().Any("webhooks", webhooks).Msg("webhook message")
 
+		log.Ctx(ctx).Info().Int64("user_id", session.UserID).Msg("online access token issued")
 		return nil
 	}
 
-	token, err := uc.shopifyClient.GetAccessToken(req.GetShop(), req.GetCode())
+	_, err = uc.authRepository.Upsert(ctx, model.ShopifyAuth{
+		Shop:        req.GetShop(),
+		AccessToken: token.AccessToken,
+		Scope:       token.Scope,
+	})
 	if err != nil {
-		log.Error().Err(err).Msg("failed get access token")
 		return err
 	}
+	uc.accessTokenChecks.save(req.GetShop(), token.AccessToken)
 
-	_, err = uc.authRepository.Save(ctx, model.ShopifyAuth{
-		Shop:        req.GetShop(),
+	if !auth.IsEmpty() {
+		log.Ctx(ctx).Info().Str("scope", token.Scope).Msg("access token refreshed")
+		return nil
+	}
+
+	uc.registerWebhook(ctx, req.GetShop(), token.AccessToken)
+
+	uc.startProductBackfill(ctx, req.GetShop())
+
+	return nil
+}
+
+// AuthorizeSession makes sure the shop of a valid session token is
+// installed. Shops loading the app for the first time, missing a scope or
+// whose access token was revoked are installed silently by exchanging the
+// session token for an offline access token.
+func (uc *shopifyUsecase) AuthorizeSession(ctx context.Context, session *shopify.SessionToken) error {
+	shop := session.Shop()
+	ctx = log.Ctx(ctx).With().Str("shop", shop).Logger().WithContext(ctx)
+
+	auth, err := uc.authRepository.FindByShop(ctx, shop)
+	if err != nil {
+		return err
+	}
+
+	installed := !auth.IsEmpty() && auth.AccessToken != ""
+	if installed && auth.HasScopes(scopes) {
+		installed, err = uc.checkAccessToken(ctx, auth)
+		if installed || err != nil {
+			return err
+		}
+	}
+
+	token, err := uc.shopifyClient.ExchangeToken(ctx, shop, session.Raw, shopify.OfflineAccessToken)
+	if shopify.IsUnauthorized(err) || shopify.IsBadRequest(err) {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to exchange session token")
+		return err
+	}
+
+	_, err = uc.authRepository.Upsert(ctx, model.ShopifyAuth{
+		Shop:        shop,
 		AccessToken: token.AccessToken,
+		Scope:       token.Scope,
 	})
 	if err != nil {
 		return err
 	}
+	uc.accessTokenChecks.save(shop, token.AccessToken)
 
-	uc.registerWebhook(req.GetShop(), token.AccessToken)
+	if installed {
+		log.Ctx(ctx).Info().Str("scope", token.Scope).Msg("access token refreshed")
+		return nil
+	}
+
+	log.Ctx(ctx).Info().Str("scope", token.Scope).Msg("installed with token exchange")
+	uc.registerWebhook(ctx, shop, token.AccessToken)
+
+	uc.startProductBackfill(ctx, shop)
 
 	return nil
 }
 
-func (uc *shopifyUsecase) registerWebhook(shop, accessToken string) {
-	topics := []webhookTopic{
-		productCreatedTopic,
-		productUpdatedTopic,
-		productDeletedTopic,
-		appUninstalledTopic,
+func (uc *shopifyUsecase) AuthorizeUser(ctx context.Context, session *shopify.SessionToken) (model.ShopifySession, error) {
+	userId, err := strconv.ParseInt(session.UserID(), 10, 64)
+	if err != nil {
+		return model.ShopifySession{}, fmt.Errorf("%w: invalid user %q", ErrUnauthorized, session.UserID())
 	}
 
+	shop := session.Shop()
+	ctx = log.Ctx(ctx).With().Str("shop", shop).Int64("user_id", userId).Logger().WithContext(ctx)
+
+	result, err := uc.sessionRepository.FindByShopAndUser(ctx, shop, userId)
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	if !result.IsEmpty() && !result.IsExpired(sessionExpiryMargin) {
+		return result, nil
+	}
+
+	token, err := uc.shopifyClient.ExchangeToken(ctx, shop, session.Raw, shopify.OnlineAccessToken)
+	if shopify.IsUnauthorized(err) || shopify.IsBadRequest(err) {
+		return model.ShopifySession{}, fmt.Errorf("%w: %w", ErrUnauthorized, err)
+	}
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("failed to exchange session token")
+		return model.ShopifySession{}, err
+	}
+
+	if token.AssociatedUser == nil || token.AssociatedUser.ID != userId {
+		return model.ShopifySession{}, fmt.Errorf("%w: online access token issued for another user", ErrUnauthorized)
+	}
+
+	result, err = uc.sessionRepository.Upsert(ctx, newShopifySession(shop, token))
+	if err != nil {
+		return model.ShopifySession{}, err
+	}
+
+	log.Ctx(ctx).Info().Msg("online access token issued")
+
+	return result, nil
+}
+
+// newShopifySession returns the session of an online access token, which
+// never expires when Shopify gives no expires_in.
+func newShopifySession(shop string, token *shopify.TokenResponse) model.ShopifySession {
+	var expiresAt *time.Time
+	if token.ExpiresIn > 0 {
+		expiry := time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+		expiresAt = &expiry
+	}
+	user := token.AssociatedUser
+
+	return model.ShopifySession{
+		Shop:                shop,
+		UserID:              user.ID,
+		AccessToken:         token.AccessToken,
+		Scope:               token.Scope,
+		AssociatedUserScope: token.AssociatedUserScope,
+		AssociatedUser: model.AssociatedUser{
+			FirstName:     user.FirstName,
+			LastName:      user.LastName,
+			Email:         user.Email,
+			EmailVerified: user.EmailVerified,
+			AccountOwner:  user.AccountOwner,
+			Locale:        user.Locale,
+			Collaborator:  user.Collaborator,
+		},
+		ExpiresAt: expiresAt,
+	}
+}
+
+// desiredWebhook is the subscription expected for topic.
+func (uc *shopifyUsecase) desiredWebhook(topic webhookTopic) shopify.Webhook {
+	return shopify.Webhook{
+		Address: uc.serverUrl + "/webhook",
+		Topic:   string(topic),
+		Format:  "json",
+	}
+}
+
+// registerWebhook subscribes a newly installed shop to webhookTopics, the
+// subscriptions failing here are created by the next reconciliation.
+func (uc *shopifyUsecase) registerWebhook(ctx context.Context, shop, accessToken string) {
 	var wg sync.WaitGroup
 
-	for _, topic := range topics {
+	for _, topic := range webhookTopics {
 		wg.Add(1)
 
-		go func(topic string) {
+		go func(topic webhookTopic) {
 			defer wg.Done()
 
-			request := shopify.Webhook{
-				Address: uc.serverUrl + "/webhook",
-				Topic:   string(topic),
-				Format:  "json",
-			}
-			webhook, err := uc.shopifyClient.CreateWebhook(shop, accessToken, request)
+			webhook, err := uc.shopifyClient.CreateWebhook(ctx, shop, accessToken, uc.desiredWebhook(topic))
 			if err != nil {
-				log.Err(err).Msg(webhook.Topic + "error create product/create webhook")
+				if shopify.IsUnprocessable(err) {
+					log.Ctx(ctx).Info().Err(err).Msg(string(topic) + " webhook already registered")
+					return
+				}
+				log.Ctx(ctx).Error().Err(err).Msg(string(topic) + " error create webhook")
+				return
 			}
-			log.Info().Any("webhook", webhook).Msg(webhook.Topic + " webhook created")
-		}(string(topic))
+			log.Ctx(ctx).Info().Any("webhook", webhook).Msg(webhook.Topic + " webhook created")
+		}(topic)
 	}
 
-	// uninstall webhook
-	// for _, webhook := range webhooks {
-	// 	wg.Add(1)
-
-	// 	go func(id int64) {
-	// 		defer wg.Done()
-
-	// 		err := uc.shopifyClient.DeleteWebhook(shop, accessToken, id)
-	// 		if err != nil {
-	// 			log.Error().Err(err).Msg("error delete webhook")
-	// 		}
-	// 	}(webhook.ID)
-	// }
-
 	wg.Wait()
 }
@@ -0,0 +1,239 @@
+package usecase
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/config"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/repository"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify/shopifytest"
+)
+
+const (
+	testAPIKey    = "api-key"
+	testAPISecret = "api-secret"
+)
+
+type shopifyTest struct {
+	usecase  *shopifyUsecase
+	server   *shopifytest.Server
+	auth     repository.AuthRepository
+	session  repository.SessionRepository
+	backfill repository.ProductBackfillRepository
+}
+
+// newShopifyTest returns a usecase talking to a fake Shopify store whose
+// shop is already installed with a valid access token.
+func newShopifyTest(t *testing.T) *shopifyTest {
+	t.Helper()
+
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	t.Cleanup(server.Close)
+
+	config.Set("SHOPIFY_CLIENT_ID", testAPIKey)
+	config.Set("SHOPIFY_CLIENT_SECRET", testAPISecret)
+	config.Set("SERVER_URL", "https://app.example.com")
+
+	client, err := shopify.NewClient(nil,
+		shopify.WithCredentials(testAPIKey, testAPISecret),
+		shopify.WithBaseURLResolver(server.BaseURLResolver()),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	st := &shopifyTest{
+		server:   server,
+		auth:     repository.NewMemoryAuthRepository(),
+		session:  repository.NewMemorySessionRepository(),
+		backfill: repository.NewMemoryProductBackfillRepository(),
+	}
+
+	uc, err := NewShopifyUsecase(
+		client,
+		st.auth,
+		repository.NewMemoryNonceRepository(),
+		st.session,
+		repository.NewMemoryProductRepository(),
+		st.backfill,
+	)
+	if err != nil {
+		t.Fatalf("NewShopifyUsecase() error = %v", err)
+	}
+	st.usecase = uc.(*shopifyUsecase)
+	t.Cleanup(func() { st.usecase.jobs.cancel(server.Shop) })
+
+	_, err = st.auth.Upsert(context.Background(), model.ShopifyAuth{
+		Shop:        server.Shop,
+		AccessToken: server.IssueAccessToken(),
+		Scope:       scopes,
+	})
+	if err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	return st
+}
+
+func (st *shopifyTest) storedAuth(t *testing.T) model.ShopifyAuth {
+	t.Helper()
+
+	auth, err := st.auth.FindByShop(context.Background(), st.server.Shop)
+	if err != nil {
+		t.Fatalf("FindByShop() error = %v", err)
+	}
+
+	return auth
+}
+
+func TestRequestAuthorizationChecksAccessToken(t *testing.T) {
+	st := newShopifyTest(t)
+	ctx := context.Background()
+	req := RequestAuthorizationRequest{Url: &url.URL{RawQuery: "shop=" + st.server.Shop}}
+
+	redirect, err := st.usecase.RequestAuthorization(ctx, req)
+	if err != nil {
+		t.Fatalf("RequestAuthorization() error = %v", err)
+	}
+	if !strings.HasPrefix(redirect, "https://app.example.com/app") {
+		t.Errorf("RequestAuthorization() = %s, want the app of an installed shop", redirect)
+	}
+
+	// the app/uninstalled webhook was missed, then the shop reinstalls
+	st.server.RevokeAccessToken(st.storedAuth(t).AccessToken)
+	st.usecase.accessTokenChecks.forget(st.server.Shop)
+
+	redirect, err = st.usecase.RequestAuthorization(ctx, req)
+	if err != nil {
+		t.Fatalf("RequestAuthorization() error = %v", err)
+	}
+	if !strings.Contains(redirect, "/admin/oauth/authorize") {
+		t.Errorf("RequestAuthorization() = %s, want the OAuth of the revoked shop", redirect)
+	}
+	if auth := st.storedAuth(t); !auth.IsEmpty() {
+		t.Errorf("auth with a revoked token = %+v, want it uninstalled", auth)
+	}
+}
+
+func TestAuthorizeSessionReplacesRevokedToken(t *testing.T) {
+	st := newShopifyTest(t)
+	ctx := context.Background()
+	revoked := st.storedAuth(t).AccessToken
+	st.server.RevokeAccessToken(revoked)
+
+	session, err := shopify.ParseSessionToken(st.server.SessionToken(42), testAPIKey, testAPISecret, 0, time.Now())
+	if err != nil {
+		t.Fatalf("ParseSessionToken() error = %v", err)
+	}
+
+	if err := st.usecase.AuthorizeSession(ctx, session); err != nil {
+		t.Fatalf("AuthorizeSession() error = %v", err)
+	}
+
+	auth := st.storedAuth(t)
+	if auth.IsEmpty() || auth.AccessToken == revoked {
+		t.Errorf("auth after AuthorizeSession() = %+v, want a new access token", auth)
+	}
+
+	// the shop was installed again
+	if webhooks := st.server.Webhooks(); len(webhooks) != len(webhookTopics) {
+		t.Errorf("webhooks after AuthorizeSession() = %+v, want %d", webhooks, len(webhookTopics))
+	}
+
+	// the new token is trusted without asking Shopify again
+	for i := 0; i < 2; i++ {
+		if err := st.usecase.AuthorizeSession(ctx, session); err != nil {
+			t.Fatalf("AuthorizeSession() error = %v", err)
+		}
+	}
+	checks := 0
+	for _, r := range st.server.Requests() {
+		if r.URL.Path == "/admin/oauth/access_scopes.json" {
+			checks++
+		}
+	}
+	if checks != 1 {
+		t.Errorf("access scopes requests = %d, want only the one of the revoked token", checks)
+	}
+}
+
+func (st *shopifyTest) tokenRequests() int {
+	count := 0
+	for _, r := range st.server.Requests() {
+		if r.URL.Path == "/admin/oauth/access_token" {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAuthorizeUserRenewsExpiringToken(t *testing.T) {
+	st := newShopifyTest(t)
+	ctx := context.Background()
+
+	session, err := shopify.ParseSessionToken(st.server.SessionToken(42), testAPIKey, testAPISecret, 0, time.Now())
+	if err != nil {
+		t.Fatalf("ParseSessionToken() error = %v", err)
+	}
+
+	first, err := st.usecase.AuthorizeUser(ctx, session)
+	if err != nil {
+		t.Fatalf("AuthorizeUser() error = %v", err)
+	}
+	if first.UserID != 42 || first.ExpiresAt == nil || time.Until(*first.ExpiresAt) < 23*time.Hour {
+		t.Errorf("AuthorizeUser() = %+v, want an online session of user 42 expiring in a day", first)
+	}
+
+	// the stored token is used until it is about to expire
+	if cached, err := st.usecase.AuthorizeUser(ctx, session); err != nil || cached.AccessToken != first.AccessToken {
+		t.Errorf("AuthorizeUser() = %+v, %v, want the stored session", cached, err)
+	}
+
+	expiresAt := time.Now().Add(sessionExpiryMargin / 2)
+	first.ExpiresAt = &expiresAt
+	if _, err := st.session.Upsert(ctx, first); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+
+	renewed, err := st.usecase.AuthorizeUser(ctx, session)
+	if err != nil {
+		t.Fatalf("AuthorizeUser() error = %v", err)
+	}
+	if renewed.AccessToken == first.AccessToken || !renewed.ExpiresAt.After(expiresAt) {
+		t.Errorf("AuthorizeUser() = %+v, want a renewed session", renewed)
+	}
+	if requests := st.tokenRequests(); requests != 2 {
+		t.Errorf("token requests = %d, want 2", requests)
+	}
+}
+
+func TestAuthorizeUserWithoutExpiry(t *testing.T) {
+	st := newShopifyTest(t)
+	ctx := context.Background()
+	st.server.OnlineTokenExpiresIn = 0
+
+	session, err := shopify.ParseSessionToken(st.server.SessionToken(42), testAPIKey, testAPISecret, 0, time.Now())
+	if err != nil {
+		t.Fatalf("ParseSessionToken() error = %v", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		result, err := st.usecase.AuthorizeUser(ctx, session)
+		if err != nil {
+			t.Fatalf("AuthorizeUser() error = %v", err)
+		}
+		if result.ExpiresAt != nil {
+			t.Errorf("AuthorizeUser() expires at %s, want never", result.ExpiresAt)
+		}
+	}
+
+	// a token without expires_in is not renewed on every request
+	if requests := st.tokenRequests(); requests != 1 {
+		t.Errorf("token requests = %d, want 1", requests)
+	}
+}
@@ -0,0 +1,149 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+// ErrInvalidWebhook is returned for webhooks missing a header or whose body
+// cannot be decoded, Shopify should not retry them.
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+type webhookHandler func(ctx context.Context, req WebhookRequest) error
+
+// WebhookRequest is a webhook delivery whose HMAC has already been verified
+// by the caller.
+type WebhookRequest struct {
+	Header http.Header
+	Body   []byte
+}
+
+func (r *WebhookRequest) GetTopic() string {
+	return r.Header.Get(shopify.WebhookTopicHeader)
+}
+
+func (r *WebhookRequest) GetShop() string {
+	return r.Header.Get(shopify.WebhookShopDomainHeader)
+}
+
+func (r *WebhookRequest) GetWebhookId() string {
+	return r.Header.Get(shopify.WebhookIdHeader)
+}
+
+func (r *WebhookRequest) Validate() error {
+	if r.GetTopic() == "" {
+		return fmt.Errorf(`%w: missing "`+shopify.WebhookTopicHeader+`" header`, ErrInvalidWebhook)
+	}
+
+	if r.GetShop() == "" {
+		return fmt.Errorf(`%w: missing "`+shopify.WebhookShopDomainHeader+`" header`, ErrInvalidWebhook)
+	}
+
+	return nil
+}
+
+// decode unmarshals the body of the webhook into v.
+func (r *WebhookRequest) decode(v interface{}) error {
+	if err := json.Unmarshal(r.Body, v); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidWebhook, err)
+	}
+
+	return nil
+}
+
+type shopPayload struct {
+	ID              int64  `json:"id"`
+	Name            string `json:"name"`
+	Domain          string `json:"domain"`
+	MyshopifyDomain string `json:"myshopify_domain"`
+}
+
+func (uc *shopifyUsecase) webhookHandlers() map[webhookTopic]webhookHandler {
+	return map[webhookTopic]webhookHandler{
+		productCreatedTopic: uc.handleProductCreated,
+		productUpdatedTopic: uc.handleProductUpdated,
+		productDeletedTopic: uc.handleProductDeleted,
+		appUninstalledTopic: uc.handleAppUninstalled,
+	}
+}
+
+func (uc *shopifyUsecase) HandleWebhook(ctx context.Context, req WebhookRequest) error {
+	if err := req.Validate(); err != nil {
+		return err
+	}
+
+	logger := log.Ctx(ctx).With().
+		Str("topic", req.GetTopic()).
+		Str("shop", req.GetShop()).
+		Str("webhook_id", req.GetWebhookId()).
+		Logger()
+
+	handler, ok := uc.webhookHandlers()[webhookTopic(req.GetTopic())]
+	if !ok {
+		logger.Warn().Msg("unhandled webhook topic")
+		return nil
+	}
+
+	err := handler(logger.WithContext(ctx), req)
+	if err != nil {
+		logger.Error().Err(err).Msg("failed to handle webhook")
+		return err
+	}
+
+	return nil
+}
+
+func (uc *shopifyUsecase) handleProductCreated(ctx context.Context, req WebhookRequest) error {
+	var product shopify.Product
+	if err := req.decode(&product); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Int64("product_id", product.ID).Msg("product created")
+
+	return uc.syncProduct(ctx, req.GetShop(), product)
+}
+
+func (uc *shopifyUsecase) handleProductUpdated(ctx context.Context, req WebhookRequest) error {
+	var product shopify.Product
+	if err := req.decode(&product); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Int64("product_id", product.ID).Msg("product updated")
+
+	return uc.syncProduct(ctx, req.GetShop(), product)
+}
+
+func (uc *shopifyUsecase) handleProductDeleted(ctx context.Context, req WebhookRequest) error {
+	var product shopify.Product
+	if err := req.decode(&product); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Int64("product_id", product.ID).Msg("product deleted")
+
+	return uc.productRepository.Delete(ctx, req.GetShop(), product.ID)
+}
+
+func (uc *shopifyUsecase) handleAppUninstalled(ctx context.Context, req WebhookRequest) error {
+	var shop shopPayload
+	if err := req.decode(&shop); err != nil {
+		return err
+	}
+
+	// Shopify has already revoked the access token
+	if err := uc.uninstall(ctx, req.GetShop()); err != nil {
+		return err
+	}
+
+	log.Ctx(ctx).Info().Str("myshopify_domain", shop.MyshopifyDomain).Msg("app uninstalled")
+
+	return nil
+}
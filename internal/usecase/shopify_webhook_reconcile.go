@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+// webhookReconciliation is the work needed to bring the subscriptions of a
+// shop in line with the desired ones.
+type webhookReconciliation struct {
+	create []shopify.Webhook
+	update []shopify.Webhook
+	delete []shopify.Webhook
+}
+
+func (r webhookReconciliation) isEmpty() bool {
+	return len(r.create) == 0 && len(r.update) == 0 && len(r.delete) == 0
+}
+
+// diffWebhooks compares the subscriptions of a shop with the desired ones.
+// A subscription to a desired topic pointing at another address, e.g. an old
+// SERVER_URL, is updated unless the topic already has a matching one. The
+// remaining subscriptions, to other topics or duplicated, are deleted.
+func diffWebhooks(existing []shopify.Webhook, desired []shopify.Webhook) webhookReconciliation {
+	var result webhookReconciliation
+
+	wanted := map[string]shopify.Webhook{}
+	for _, webhook := range desired {
+		wanted[webhook.Topic] = webhook
+	}
+
+	// the matching subscriptions are picked first, so that a drifted
+	// duplicate is deleted instead of updated
+	kept := map[string]int64{}
+	for _, webhook := range existing {
+		want, ok := wanted[webhook.Topic]
+		if _, done := kept[webhook.Topic]; ok && !done && webhook.Address == want.Address && webhook.Format == want.Format {
+			kept[webhook.Topic] = webhook.ID
+		}
+	}
+
+	for _, webhook := range existing {
+		want, ok := wanted[webhook.Topic]
+		id, done := kept[webhook.Topic]
+		switch {
+		case ok && done && id == webhook.ID:
+		case ok && !done:
+			want.ID = webhook.ID
+			result.update = append(result.update, want)
+			kept[webhook.Topic] = webhook.ID
+		default:
+			result.delete = append(result.delete, webhook)
+		}
+	}
+
+	for _, webhook := range desired {
+		if _, ok := kept[webhook.Topic]; !ok {
+			result.create = append(result.create, webhook)
+		}
+	}
+
+	return result
+}
+
+// ReconcileWebhooks lists the subscriptions of every installed shop and
+// creates the missing ones, updates the ones pointing at another address
+// and deletes the stale ones. Shopify removes the subscriptions failing for
+// too long, and registerWebhook only logs its errors.
+func (uc *shopifyUsecase) ReconcileWebhooks(ctx context.Context) error {
+	auths, err := uc.authRepository.FindAll(ctx)
+	if err != nil {
+		return err
+	}
+
+	desired := make([]shopify.Webhook, 0, len(webhookTopics))
+	for _, topic := range webhookTopics {
+		desired = append(desired, uc.desiredWebhook(topic))
+	}
+
+	var errs []error
+	for _, auth := range auths {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// the auth of an uninstalled shop is kept without its access token
+		if auth.DeletedAt != nil || auth.AccessToken == "" {
+			continue
+		}
+
+		shopCtx := log.Ctx(ctx).With().Str("shop", auth.Shop).Logger().WithContext(ctx)
+
+		err := uc.reconcileShopWebhooks(shopCtx, auth.Shop, auth.AccessToken, desired)
+		if shopify.IsUnauthorized(err) {
+			// the app/uninstalled webhook of the shop was missed
+			log.Ctx(shopCtx).Warn().Err(err).Msg("access token revoked, uninstalling the shop")
+			if err := uc.uninstall(shopCtx, auth.Shop); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", auth.Shop, err))
+			}
+			continue
+		}
+		if err != nil {
+			log.Ctx(shopCtx).Error().Err(err).Msg("failed to reconcile webhooks")
+			errs = append(errs, fmt.Errorf("%s: %w", auth.Shop, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (uc *shopifyUsecase) reconcileShopWebhooks(ctx context.Context, shop, accessToken string, desired []shopify.Webhook) error {
+	existing, err := uc.shopifyClient.ListWebhook(ctx, shop, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	reconciliation := diffWebhooks(existing, desired)
+	if reconciliation.isEmpty() {
+		return nil
+	}
+
+	var errs []error
+	for _, webhook := range reconciliation.create {
+		_, err := uc.shopifyClient.CreateWebhook(ctx, shop, accessToken, webhook)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("create %s webhook: %w", webhook.Topic, err))
+			continue
+		}
+		log.Ctx(ctx).Info().Str("topic", webhook.Topic).Msg("missing webhook created")
+	}
+
+	for _, webhook := range reconciliation.update {
+		_, err := uc.shopifyClient.UpdateWebhook(ctx, shop, accessToken, webhook)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("update %s webhook %d: %w", webhook.Topic, webhook.ID, err))
+			continue
+		}
+		log.Ctx(ctx).Info().Str("topic", webhook.Topic).Int64("webhook_id", webhook.ID).Msg("drifted webhook updated")
+	}
+
+	for _, webhook := range reconciliation.delete {
+		err := uc.shopifyClient.DeleteWebhook(ctx, shop, accessToken, webhook.ID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("delete %s webhook %d: %w", webhook.Topic, webhook.ID, err))
+			continue
+		}
+		log.Ctx(ctx).Info().
+			Str("topic", webhook.Topic).
+			Str("address", webhook.Address).
+			Int64("webhook_id", webhook.ID).
+			Msg("stale webhook deleted")
+	}
+
+	return errors.Join(errs...)
+}
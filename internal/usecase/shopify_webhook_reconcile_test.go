@@ -0,0 +1,85 @@
+package usecase
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+func TestDiffWebhooks(t *testing.T) {
+	const (
+		address    = "https://app.example.com/webhook"
+		oldAddress = "https://old.example.com/webhook"
+	)
+
+	webhook := func(id int64, topic string, address string) shopify.Webhook {
+		return shopify.Webhook{ID: id, Topic: topic, Address: address, Format: "json"}
+	}
+
+	desired := []shopify.Webhook{
+		webhook(0, "products/create", address),
+		webhook(0, "products/update", address),
+	}
+
+	tests := []struct {
+		name     string
+		existing []shopify.Webhook
+		want     webhookReconciliation
+	}{
+		{
+			name:     "matching",
+			existing: []shopify.Webhook{webhook(1, "products/create", address), webhook(2, "products/update", address)},
+			want:     webhookReconciliation{},
+		},
+		{
+			name:     "missing",
+			existing: []shopify.Webhook{webhook(1, "products/create", address)},
+			want:     webhookReconciliation{create: []shopify.Webhook{webhook(0, "products/update", address)}},
+		},
+		{
+			name:     "drifted",
+			existing: []shopify.Webhook{webhook(1, "products/create", address), webhook(2, "products/update", oldAddress)},
+			want:     webhookReconciliation{update: []shopify.Webhook{webhook(2, "products/update", address)}},
+		},
+		{
+			name: "drifted duplicate of a matching subscription",
+			existing: []shopify.Webhook{
+				webhook(1, "products/create", oldAddress),
+				webhook(2, "products/create", address),
+				webhook(3, "products/update", address),
+			},
+			want: webhookReconciliation{delete: []shopify.Webhook{webhook(1, "products/create", oldAddress)}},
+		},
+		{
+			name: "drifted duplicates",
+			existing: []shopify.Webhook{
+				webhook(1, "products/create", address),
+				webhook(2, "products/update", oldAddress),
+				webhook(3, "products/update", oldAddress),
+			},
+			want: webhookReconciliation{
+				update: []shopify.Webhook{webhook(2, "products/update", address)},
+				delete: []shopify.Webhook{webhook(3, "products/update", oldAddress)},
+			},
+		},
+		{
+			name: "stale topic",
+			existing: []shopify.Webhook{
+				webhook(1, "products/create", address),
+				webhook(2, "products/update", address),
+				webhook(3, "orders/create", address),
+			},
+			want: webhookReconciliation{delete: []shopify.Webhook{webhook(3, "orders/create", address)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := diffWebhooks(tt.existing, desired)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("diffWebhooks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package usecase
+
+import (
+	"context"
+	"testing"
+
+	"github.com/zeals-co-ltd/shopify-app-example/internal/model"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+func TestHandleAppUninstalled(t *testing.T) {
+	st := newShopifyTest(t)
+	ctx := context.Background()
+	products := newBlockingProductRepository()
+	st.usecase.productRepository = products
+	st.server.AddProduct(shopify.Product{Title: "product"})
+
+	if _, err := st.session.Upsert(ctx, model.ShopifySession{Shop: st.server.Shop, UserID: 1, AccessToken: "online"}); err != nil {
+		t.Fatalf("Upsert() error = %v", err)
+	}
+	st.usecase.startProductBackfill(ctx, st.server.Shop)
+	<-products.blocked
+
+	req := newWebhookRequest(t, "app/uninstalled", map[string]string{"myshopify_domain": st.server.Shop})
+	req.Header.Set(shopify.WebhookShopDomainHeader, st.server.Shop)
+	if err := st.usecase.HandleWebhook(ctx, req); err != nil {
+		t.Fatalf("HandleWebhook() error = %v", err)
+	}
+	close(products.released)
+
+	if auth := st.storedAuth(t); !auth.IsEmpty() {
+		t.Errorf("auth after app/uninstalled = %+v, want it soft-deleted", auth)
+	}
+	if session, _ := st.session.FindByShopAndUser(ctx, st.server.Shop, 1); !session.IsEmpty() {
+		t.Errorf("session after app/uninstalled = %+v, want none", session)
+	}
+	st.waitProductBackfill(t, model.ProductBackfillCancelled)
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 	"runtime/debug"
+	"time"
 
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"github.com/zeals-co-ltd/shopify-app-example/internal/adapter"
 	"github.com/zeals-co-ltd/shopify-app-example/internal/config"
+	"github.com/zeals-co-ltd/shopify-app-example/internal/encryption"
 	"github.com/zeals-co-ltd/shopify-app-example/internal/repository"
 	"github.com/zeals-co-ltd/shopify-app-example/internal/usecase"
 	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
@@ -17,9 +20,14 @@ import (
 )
 
 func main() {
+	rotateTokenKeys := flag.Bool("rotate-token-keys", false, "encrypt every access token with TOKEN_ENCRYPTION_KEY_ID and exit")
+	flag.Parse()
+
 	config.Load(".env")
 
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
+	// log.Ctx falls back to the global logger outside of requests and jobs
+	zerolog.DefaultContextLogger = &log.Logger
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -27,8 +35,24 @@ func main() {
 		}
 	}()
 
+	apiKey, err := config.MustGet("SHOPIFY_CLIENT_ID")
+	if err != nil {
+		log.Err(err).Msg("failed to get SHOPIFY_CLIENT_ID")
+		return
+	}
+
+	apiSecret, err := config.MustGet("SHOPIFY_CLIENT_SECRET")
+	if err != nil {
+		log.Err(err).Msg("failed to get SHOPIFY_CLIENT_SECRET")
+		return
+	}
+
 	httpClient := &http.Client{}
-	shopifyClient, err := shopify.NewClient(httpClient)
+	shopifyClient, err := shopify.NewClient(
+		httpClient,
+		shopify.WithCredentials(apiKey, apiSecret),
+		shopify.WithUserAgent("shopify-app-example"),
+	)
 	if err != nil {
 		log.Err(err).Msg("failed to initiate ShopifyClient")
 		return
@@ -37,28 +61,99 @@ func main() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI("mongodb://localhost:27017"))
+	driver := config.Get("DATABASE_DRIVER", "mongo")
+
+	var keyring *encryption.Keyring
+	if keys := config.Get("TOKEN_ENCRYPTION_KEYS", ""); keys == "" && driver == "memory" {
+		// the tokens are lost on restart anyway
+		log.Warn().Msg("TOKEN_ENCRYPTION_KEYS is not set, encrypting the access tokens with an ephemeral key")
+		keyring, err = encryption.NewEphemeralKeyring()
+	} else {
+		keyring, err = encryption.ParseKeyring(config.Get("TOKEN_ENCRYPTION_KEY_ID", ""), keys)
+	}
 	if err != nil {
-		log.Err(err).Msg("failed to initiate mongo client")
+		log.Err(err).Msg("failed to initiate token encryption keyring")
 		return
 	}
-	defer mongoClient.Disconnect(ctx)
 
 	// repository
-	authRepository, err := repository.NewAuthRepository(mongoClient.Database("shopify_db"))
+	var repos *repositories
+	switch driver {
+	case "memory":
+		log.Warn().Msg("using in-memory repositories, data is lost on restart")
+		repos = newMemoryRepositories()
+	case "mongo":
+		mongoClient, err := mongo.Connect(ctx, options.Client().ApplyURI(config.Get("MONGO_URI", "mongodb://localhost:27017")))
+		if err != nil {
+			log.Err(err).Msg("failed to initiate mongo client")
+			return
+		}
+		defer mongoClient.Disconnect(ctx)
+
+		repos, err = newMongoRepositories(ctx, mongoClient.Database("shopify_db"))
+		if err != nil {
+			log.Err(err).Msg("failed to initiate repository")
+			return
+		}
+	default:
+		log.Error().Str("driver", driver).Msg("unknown DATABASE_DRIVER")
+		return
+	}
+
+	authRepository, err := repository.NewEncryptedAuthRepository(repos.auth, keyring)
+	if err != nil {
+		log.Err(err).Msg("failed to initiate repository")
+		return
+	}
+
+	if *rotateTokenKeys {
+		rotated, err := authRepository.RotateKeys(ctx)
+		if err != nil {
+			log.Err(err).Int("rotated", rotated).Msg("failed to rotate token keys")
+			return
+		}
+
+		log.Info().Int("rotated", rotated).Str("key_id", keyring.PrimaryKeyID()).Msg("token keys rotated")
+		return
+	}
+
+	sessionRepository, err := repository.NewEncryptedSessionRepository(repos.session, keyring)
 	if err != nil {
 		log.Err(err).Msg("failed to initiate repository")
 		return
 	}
 
 	// usecase
-	shopifyUsecase, err := usecase.NewShopifyUsecase(shopifyClient, authRepository)
+	shopifyUsecase, err := usecase.NewShopifyUsecase(shopifyClient, authRepository, repos.nonce, sessionRepository, repos.product, repos.productBackfill)
 	if err != nil {
 		log.Err(err).Msg("failed to initiate shopifyUsecase")
 		return
 	}
 
-	httpServer, err := adapter.NewHttpServer(shopifyClient, shopifyUsecase)
+	complianceUsecase, err := usecase.NewComplianceUsecase(authRepository, repos.compliance, sessionRepository, repos.product, repos.productBackfill)
+	if err != nil {
+		log.Err(err).Msg("failed to initiate complianceUsecase")
+		return
+	}
+
+	// the backfills interrupted by the last shutdown
+	err = shopifyUsecase.ResumeProductBackfills(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to resume product backfills")
+	}
+
+	reconcileInterval, err := time.ParseDuration(config.Get("WEBHOOK_RECONCILE_INTERVAL", "1h"))
+	if err != nil {
+		log.Err(err).Msg("invalid WEBHOOK_RECONCILE_INTERVAL")
+		return
+	}
+	if reconcileInterval < 0 {
+		log.Error().Dur("interval", reconcileInterval).Msg("WEBHOOK_RECONCILE_INTERVAL must not be negative")
+		return
+	}
+	go reconcileWebhooks(ctx, shopifyUsecase, reconcileInterval)
+
+	httpServer, err := adapter.NewHttpServer(shopifyClient, shopifyUsecase, complianceUsecase)
 	if err != nil {
 		log.Err(err).Msg("failed to initiate HttpServer")
 		return
@@ -70,3 +165,94 @@ func main() {
 		return
 	}
 }
+
+// reconcileWebhooks repairs the webhook subscriptions at startup, e.g. after
+// a change of SERVER_URL, then every interval until ctx is cancelled. An
+// interval of 0 only reconciles them at startup.
+func reconcileWebhooks(ctx context.Context, shopifyUsecase usecase.ShopifyUsecase, interval time.Duration) {
+	err := shopifyUsecase.ReconcileWebhooks(ctx)
+	if err != nil {
+		log.Err(err).Msg("failed to reconcile webhooks")
+	}
+
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		err := shopifyUsecase.ReconcileWebhooks(ctx)
+		if err != nil {
+			log.Err(err).Msg("failed to reconcile webhooks")
+		}
+	}
+}
+
+type repositories struct {
+	auth            repository.AuthRepository
+	nonce           repository.NonceRepository
+	compliance      repository.ComplianceRepository
+	session         repository.SessionRepository
+	product         repository.ProductRepository
+	productBackfill repository.ProductBackfillRepository
+}
+
+func newMongoRepositories(ctx context.Context, db *mongo.Database) (*repositories, error) {
+	authRepository, err := repository.NewAuthRepository(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	nonceRepository, err := repository.NewNonceRepository(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	complianceRepository, err := repository.NewComplianceRepository(db)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionRepository, err := repository.NewSessionRepository(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	productRepository, err := repository.NewProductRepository(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	productBackfillRepository, err := repository.NewProductBackfillRepository(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &repositories{
+		auth:            authRepository,
+		nonce:           nonceRepository,
+		compliance:      complianceRepository,
+		session:         sessionRepository,
+		product:         productRepository,
+		productBackfill: productBackfillRepository,
+	}, nil
+}
+
+func newMemoryRepositories() *repositories {
+	return &repositories{
+		auth:            repository.NewMemoryAuthRepository(),
+		nonce:           repository.NewMemoryNonceRepository(),
+		compliance:      repository.NewMemoryComplianceRepository(),
+		session:         repository.NewMemorySessionRepository(),
+		product:         repository.NewMemoryProductRepository(),
+		productBackfill: repository.NewMemoryProductBackfillRepository(),
+	}
+}
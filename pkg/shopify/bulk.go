@@ -0,0 +1,391 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status of a bulk operation.
+const (
+	BulkOperationCreated   = "CREATED"
+	BulkOperationRunning   = "RUNNING"
+	BulkOperationCompleted = "COMPLETED"
+	BulkOperationCanceling = "CANCELING"
+	BulkOperationCanceled  = "CANCELED"
+	BulkOperationFailed    = "FAILED"
+	BulkOperationExpired   = "EXPIRED"
+)
+
+const bulkOperationRunQueryMutation = `
+mutation bulkOperationRunQuery($query: String!) {
+  bulkOperationRunQuery(query: $query) {
+    bulkOperation {
+      id
+      status
+    }
+    userErrors {
+      field
+      message
+    }
+  }
+}`
+
+const currentBulkOperationQuery = `
+query currentBulkOperation {
+  currentBulkOperation {
+    id
+    status
+    errorCode
+    createdAt
+    completedAt
+    objectCount
+    fileSize
+    url
+    partialDataUrl
+    query
+  }
+}`
+
+// BulkProductsQuery exports every product with its variants, it is meant to
+// be decoded with DecodeBulkProducts.
+const BulkProductsQuery = `
+{
+  products {
+    edges {
+      node {
+        id
+        title
+        handle
+        vendor
+        productType
+        status
+        tags
+        descriptionHtml
+        createdAt
+        updatedAt
+        publishedAt
+        variants {
+          edges {
+            node {
+              id
+              title
+              sku
+              barcode
+              price
+              compareAtPrice
+              position
+              inventoryQuantity
+              createdAt
+              updatedAt
+            }
+          }
+        }
+      }
+    }
+  }
+}`
+
+type BulkOperationService interface {
+	// RunBulkQuery starts a bulk query, only one can run at a time per shop.
+	RunBulkQuery(ctx context.Context, shop string, accessToken string, query string) (*BulkOperation, error)
+	CurrentBulkOperation(ctx context.Context, shop string, accessToken string) (*BulkOperation, error)
+	// WaitBulkOperation polls the current bulk operation every interval until
+	// the operation with the given id is finished.
+	WaitBulkOperation(ctx context.Context, shop string, accessToken string, id string, interval time.Duration) (*BulkOperation, error)
+	// DownloadBulkOperation opens the JSONL result of a completed operation,
+	// the caller must close it.
+	DownloadBulkOperation(ctx context.Context, operation *BulkOperation) (io.ReadCloser, error)
+}
+
+type BulkOperation struct {
+	ID             string     `json:"id"`
+	Status         string     `json:"status"`
+	ErrorCode      string     `json:"errorCode,omitempty"`
+	CreatedAt      *time.Time `json:"createdAt,omitempty"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	ObjectCount    string     `json:"objectCount,omitempty"`
+	FileSize       string     `json:"fileSize,omitempty"`
+	Url            string     `json:"url,omitempty"`
+	PartialDataUrl string     `json:"partialDataUrl,omitempty"`
+	Query          string     `json:"query,omitempty"`
+}
+
+func (o *BulkOperation) IsFinished() bool {
+	switch o.Status {
+	case BulkOperationCompleted, BulkOperationCanceled, BulkOperationFailed, BulkOperationExpired:
+		return true
+	}
+
+	return false
+}
+
+type BulkProduct struct {
+	ID              string        `json:"id"`
+	Title           string        `json:"title"`
+	Handle          string        `json:"handle"`
+	Vendor          string        `json:"vendor"`
+	ProductType     string        `json:"productType"`
+	Status          string        `json:"status"`
+	Tags            []string      `json:"tags"`
+	DescriptionHtml string        `json:"descriptionHtml"`
+	CreatedAt       *time.Time    `json:"createdAt,omitempty"`
+	UpdatedAt       *time.Time    `json:"updatedAt,omitempty"`
+	PublishedAt     *time.Time    `json:"publishedAt,omitempty"`
+	Variants        []BulkVariant `json:"-"`
+}
+
+type BulkVariant struct {
+	ID                string     `json:"id"`
+	Title             string     `json:"title"`
+	Sku               string     `json:"sku"`
+	Barcode           string     `json:"barcode"`
+	Price             string     `json:"price"`
+	CompareAtPrice    *string    `json:"compareAtPrice"`
+	Position          int        `json:"position"`
+	InventoryQuantity int        `json:"inventoryQuantity"`
+	CreatedAt         *time.Time `json:"createdAt,omitempty"`
+	UpdatedAt         *time.Time `json:"updatedAt,omitempty"`
+}
+
+// ToProduct converts the GraphQL representation of a product into the REST
+// one.
+func (p BulkProduct) ToProduct() (Product, error) {
+	_, id, err := ParseGID(p.ID)
+	if err != nil {
+		return Product{}, err
+	}
+
+	product := Product{
+		ID:                id,
+		Title:             p.Title,
+		BodyHTML:          p.DescriptionHtml,
+		Vendor:            p.Vendor,
+		ProductType:       p.ProductType,
+		Handle:            p.Handle,
+		Status:            strings.ToLower(p.Status),
+		Tags:              strings.Join(p.Tags, ", "),
+		AdminGraphqlApiId: p.ID,
+		CreatedAt:         p.CreatedAt,
+		UpdatedAt:         p.UpdatedAt,
+		PublishedAt:       p.PublishedAt,
+	}
+
+	for _, v := range p.Variants {
+		_, variantId, err := ParseGID(v.ID)
+		if err != nil {
+			return Product{}, err
+		}
+
+		product.Variants = append(product.Variants, Variant{
+			ID:                variantId,
+			ProductID:         id,
+			Title:             v.Title,
+			Sku:               v.Sku,
+			Barcode:           v.Barcode,
+			Price:             v.Price,
+			CompareAtPrice:    v.CompareAtPrice,
+			Position:          v.Position,
+			InventoryQuantity: v.InventoryQuantity,
+			AdminGraphqlApiId: v.ID,
+			CreatedAt:         v.CreatedAt,
+			UpdatedAt:         v.UpdatedAt,
+		})
+	}
+
+	return product, nil
+}
+
+// ParseGID splits a global id such as "gid://shopify/Product/123" into its
+// resource type and numeric id.
+func ParseGID(gid string) (string, int64, error) {
+	path, found := strings.CutPrefix(gid, "gid://shopify/")
+	if !found {
+		return "", 0, fmt.Errorf("invalid gid %q", gid)
+	}
+
+	resource, rawId, found := strings.Cut(path, "/")
+	if !found {
+		return "", 0, fmt.Errorf("invalid gid %q", gid)
+	}
+
+	// ids may carry parameters, e.g. "gid://shopify/Foo/1?bar=baz"
+	rawId, _, _ = strings.Cut(rawId, "?")
+	id, err := strconv.ParseInt(rawId, 10, 64)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid gid %q: %w", gid, err)
+	}
+
+	return resource, id, nil
+}
+
+func (c *client) RunBulkQuery(ctx context.Context, shop string, accessToken string, query string) (*BulkOperation, error) {
+	var result struct {
+		BulkOperationRunQuery struct {
+			BulkOperation *BulkOperation `json:"bulkOperation"`
+		} `json:"bulkOperationRunQuery"`
+	}
+
+	variables := map[string]interface{}{"query": query}
+	err := c.GraphQL(ctx, shop, accessToken, bulkOperationRunQueryMutation, variables, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	if result.BulkOperationRunQuery.BulkOperation == nil {
+		return nil, errors.New("shopify: bulk operation was not created")
+	}
+
+	return result.BulkOperationRunQuery.BulkOperation, nil
+}
+
+func (c *client) CurrentBulkOperation(ctx context.Context, shop string, accessToken string) (*BulkOperation, error) {
+	var result struct {
+		CurrentBulkOperation *BulkOperation `json:"currentBulkOperation"`
+	}
+
+	err := c.GraphQL(ctx, shop, accessToken, currentBulkOperationQuery, nil, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.CurrentBulkOperation, nil
+}
+
+func (c *client) WaitBulkOperation(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	id string,
+	interval time.Duration,
+) (*BulkOperation, error) {
+	for {
+		operation, err := c.CurrentBulkOperation(ctx, shop, accessToken)
+		if err != nil {
+			return nil, err
+		}
+
+		if operation == nil || operation.ID != id {
+			return nil, fmt.Errorf("shopify: bulk operation %s is not the current one", id)
+		}
+
+		if operation.IsFinished() {
+			return operation, nil
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *client) DownloadBulkOperation(ctx context.Context, operation *BulkOperation) (io.ReadCloser, error) {
+	if operation.Status != BulkOperationCompleted {
+		return nil, fmt.Errorf("shopify: bulk operation %s is %s", operation.ID, operation.Status)
+	}
+
+	// an operation without any result has no file
+	if operation.Url == "" {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", operation.Url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if !(res.StatusCode >= 200 && res.StatusCode <= 299) {
+		defer res.Body.Close()
+		return nil, newAPIError(res)
+	}
+
+	return res.Body, nil
+}
+
+type bulkLine struct {
+	ID       string `json:"id"`
+	ParentID string `json:"__parentId"`
+}
+
+// DecodeBulkProducts reads the JSONL result of BulkProductsQuery one line at
+// a time and calls fn with every product once all of its variants are read.
+// Shopify writes the children of a product right after it, so only the
+// current product is held in memory.
+func DecodeBulkProducts(r io.Reader, fn func(product BulkProduct) error) error {
+	decoder := json.NewDecoder(r)
+
+	var current *BulkProduct
+	children := map[string]bool{}
+
+	for {
+		var raw json.RawMessage
+		err := decoder.Decode(&raw)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		var line bulkLine
+		if err := json.Unmarshal(raw, &line); err != nil {
+			return err
+		}
+
+		if line.ParentID == "" {
+			if current != nil {
+				if err := fn(*current); err != nil {
+					return err
+				}
+			}
+
+			current = new(BulkProduct)
+			if err := json.Unmarshal(raw, current); err != nil {
+				return err
+			}
+			children = map[string]bool{}
+			continue
+		}
+
+		if current == nil || line.ParentID != current.ID {
+			// nested deeper than the variants of the current product
+			if children[line.ParentID] {
+				continue
+			}
+			return fmt.Errorf("shopify: bulk record %s refers to unknown parent %s", line.ID, line.ParentID)
+		}
+		children[line.ID] = true
+
+		resource, _, err := ParseGID(line.ID)
+		if err != nil {
+			return err
+		}
+
+		if resource != "ProductVariant" {
+			continue
+		}
+
+		var variant BulkVariant
+		if err := json.Unmarshal(raw, &variant); err != nil {
+			return err
+		}
+		current.Variants = append(current.Variants, variant)
+	}
+
+	if current != nil {
+		return fn(*current)
+	}
+
+	return nil
+}
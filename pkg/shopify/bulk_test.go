@@ -0,0 +1,93 @@
+package shopify
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBulkProducts(t *testing.T) {
+	result := strings.Join([]string{
+		`{"id":"gid://shopify/Product/1","title":"Shirt","status":"ACTIVE","tags":["a","b"]}`,
+		`{"id":"gid://shopify/ProductVariant/11","title":"S","sku":"SHIRT-S","position":1,"__parentId":"gid://shopify/Product/1"}`,
+		`{"id":"gid://shopify/ProductImage/111","__parentId":"gid://shopify/Product/1"}`,
+		`{"id":"gid://shopify/InventoryLevel/1111","__parentId":"gid://shopify/ProductVariant/11"}`,
+		`{"id":"gid://shopify/ProductVariant/12","title":"M","sku":"SHIRT-M","position":2,"__parentId":"gid://shopify/Product/1"}`,
+		`{"id":"gid://shopify/Product/2","title":"Mug","status":"DRAFT"}`,
+		`{"id":"gid://shopify/Product/3","title":"Hat","status":"ACTIVE"}`,
+		`{"id":"gid://shopify/ProductVariant/31","title":"Default","__parentId":"gid://shopify/Product/3"}`,
+	}, "\n")
+
+	var products []Product
+	err := DecodeBulkProducts(strings.NewReader(result), func(bulkProduct BulkProduct) error {
+		product, err := bulkProduct.ToProduct()
+		if err != nil {
+			return err
+		}
+		products = append(products, product)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("DecodeBulkProducts() error = %v", err)
+	}
+
+	if len(products) != 3 {
+		t.Fatalf("DecodeBulkProducts() returned %d products, want 3", len(products))
+	}
+
+	shirt := products[0]
+	if shirt.ID != 1 || shirt.Status != "active" || shirt.Tags != "a, b" {
+		t.Errorf("products[0] = %+v", shirt)
+	}
+	if len(shirt.Variants) != 2 {
+		t.Fatalf("products[0] has %d variants, want 2", len(shirt.Variants))
+	}
+	for i, want := range []struct {
+		id  int64
+		sku string
+	}{{11, "SHIRT-S"}, {12, "SHIRT-M"}} {
+		variant := shirt.Variants[i]
+		if variant.ID != want.id || variant.Sku != want.sku || variant.ProductID != 1 {
+			t.Errorf("products[0].Variants[%d] = %+v, want id %d and sku %s", i, variant, want.id, want.sku)
+		}
+	}
+
+	if products[1].ID != 2 || len(products[1].Variants) != 0 {
+		t.Errorf("products[1] = %+v, want product 2 without variants", products[1])
+	}
+
+	if products[2].ID != 3 || len(products[2].Variants) != 1 || products[2].Variants[0].ID != 31 {
+		t.Errorf("products[2] = %+v, want product 3 with variant 31", products[2])
+	}
+}
+
+func TestDecodeBulkProductsUnknownParent(t *testing.T) {
+	result := strings.Join([]string{
+		`{"id":"gid://shopify/Product/1","title":"Shirt"}`,
+		`{"id":"gid://shopify/ProductVariant/21","__parentId":"gid://shopify/Product/2"}`,
+	}, "\n")
+
+	err := DecodeBulkProducts(strings.NewReader(result), func(product BulkProduct) error {
+		return nil
+	})
+	if err == nil {
+		t.Fatal("DecodeBulkProducts() succeeded with a record of an unknown parent")
+	}
+}
+
+func TestDecodeBulkProductsStopsOnHandlerError(t *testing.T) {
+	result := strings.Join([]string{
+		`{"id":"gid://shopify/Product/1"}`,
+		`{"id":"gid://shopify/Product/2"}`,
+	}, "\n")
+
+	stop := errors.New("stop")
+	calls := 0
+	err := DecodeBulkProducts(strings.NewReader(result), func(product BulkProduct) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) || calls != 1 {
+		t.Errorf("DecodeBulkProducts() error = %v after %d calls, want %v after 1 call", err, calls, stop)
+	}
+}
@@ -0,0 +1,81 @@
+package shopify
+
+import (
+	"net/url"
+
+	"github.com/rs/zerolog"
+)
+
+// BaseURLResolver returns the url the requests of shop are sent to.
+type BaseURLResolver func(shop string) (*url.URL, error)
+
+// ClientOption configures the client created by NewClient.
+type ClientOption func(c *client)
+
+func defaultBaseURLResolver(shop string) (*url.URL, error) {
+	return url.Parse("https://" + shop)
+}
+
+// WithBaseURLResolver sends the requests to the url returned by resolver
+// instead of https://{shop}, e.g. to use a proxy or a fake server.
+func WithBaseURLResolver(resolver BaseURLResolver) ClientOption {
+	return func(c *client) {
+		c.baseURLResolver = resolver
+	}
+}
+
+// WithAPIVersion sets the Admin API version of every request, e.g. "2026-07",
+// instead of DefaultAPIVersion.
+func WithAPIVersion(version string) ClientOption {
+	return func(c *client) {
+		c.apiVersion = version
+	}
+}
+
+// WithCredentials sets the client ID and secret of the app, they are
+// required to exchange OAuth codes for access tokens.
+func WithCredentials(apiKey string, apiSecret string) ClientOption {
+	return func(c *client) {
+		c.apiKey = apiKey
+		c.apiSecret = apiSecret
+	}
+}
+
+// WithUserAgent sets the User-Agent header of every request.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *client) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithLogger replaces the global zerolog logger.
+func WithLogger(logger zerolog.Logger) ClientOption {
+	return func(c *client) {
+		c.logger = logger
+	}
+}
+
+// WithRateLimit replaces DefaultRateLimit as the REST API bucket of every
+// shop, SetRateLimit still overrides it per shop.
+func WithRateLimit(limit RateLimit) ClientOption {
+	return func(c *client) {
+		c.rateLimiter = newRateLimiter(limit)
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy. A zero MaxRetries disables
+// the retries.
+func WithRetryPolicy(policy RetryPolicy) ClientOption {
+	return func(c *client) {
+		c.retryPolicy = policy
+	}
+}
+
+// WithDeprecationHandler calls handler for every response served with
+// another API version than requested or flagged as deprecated by Shopify.
+// Deprecations are logged regardless.
+func WithDeprecationHandler(handler DeprecationHandler) ClientOption {
+	return func(c *client) {
+		c.deprecations.handler = handler
+	}
+}
@@ -0,0 +1,191 @@
+package shopify_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify/shopifytest"
+)
+
+const (
+	testAPIKey    = "api-key"
+	testAPISecret = "api-secret"
+)
+
+func newTestClient(t *testing.T, server *shopifytest.Server, opts ...shopify.ClientOption) shopify.Client {
+	t.Helper()
+
+	opts = append([]shopify.ClientOption{
+		shopify.WithCredentials(testAPIKey, testAPISecret),
+		shopify.WithBaseURLResolver(server.BaseURLResolver()),
+	}, opts...)
+
+	client, err := shopify.NewClient(nil, opts...)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	return client
+}
+
+func TestExchangeToken(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	client := newTestClient(t, server)
+	ctx := context.Background()
+
+	offline, err := client.ExchangeToken(ctx, server.Shop, server.SessionToken(42), shopify.OfflineAccessToken)
+	if err != nil {
+		t.Fatalf("ExchangeToken() error = %v", err)
+	}
+	if offline.AccessToken == "" || offline.Scope != server.Scope || offline.AssociatedUser != nil {
+		t.Errorf("ExchangeToken() offline = %+v, want a shop access token", offline)
+	}
+
+	// the exchanged token is accepted by the Admin API
+	if _, err := client.CountProduct(ctx, server.Shop, offline.AccessToken, nil); err != nil {
+		t.Errorf("CountProduct() with the exchanged token error = %v", err)
+	}
+
+	online, err := client.ExchangeToken(ctx, server.Shop, server.SessionToken(42), shopify.OnlineAccessToken)
+	if err != nil {
+		t.Fatalf("ExchangeToken() error = %v", err)
+	}
+	if online.ExpiresIn == 0 || online.AssociatedUser == nil || online.AssociatedUser.ID != 42 {
+		t.Errorf("ExchangeToken() online = %+v, want an access token of user 42", online)
+	}
+
+	if _, err := client.ExchangeToken(ctx, server.Shop, "invalid", shopify.OfflineAccessToken); err == nil {
+		t.Error("ExchangeToken() succeeded with an invalid session token")
+	}
+}
+
+func TestListProductPagination(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	accessToken := server.IssueAccessToken()
+
+	for i := 0; i < 5; i++ {
+		vendor := "acme"
+		if i%2 == 1 {
+			vendor = "other"
+		}
+		server.AddProduct(shopify.Product{Title: "product", Vendor: vendor})
+	}
+
+	var options interface{} = &struct {
+		shopify.ListOptions
+		Vendor string `url:"vendor,omitempty"`
+	}{ListOptions: shopify.ListOptions{Limit: 2}, Vendor: "acme"}
+
+	var products []shopify.Product
+	for pages := 0; options != nil; pages++ {
+		if pages == 3 {
+			t.Fatal("ListProductWithPagination() did not stop after the last page")
+		}
+
+		page, pagination, err := client.ListProductWithPagination(ctx, server.Shop, accessToken, options)
+		if err != nil {
+			t.Fatalf("ListProductWithPagination() error = %v", err)
+		}
+		products = append(products, page...)
+
+		options = nil
+		if pagination.NextPageOptions != nil {
+			options = pagination.NextPageOptions
+		}
+	}
+
+	// the next pages keep the vendor filter of the first one
+	if len(products) != 3 {
+		t.Fatalf("ListProductWithPagination() returned %d products, want 3", len(products))
+	}
+	for _, product := range products {
+		if product.Vendor != "acme" {
+			t.Errorf("ListProductWithPagination() returned %+v, want only acme products", product)
+		}
+	}
+
+	count, err := client.CountProduct(ctx, server.Shop, accessToken, &struct {
+		Vendor string `url:"vendor"`
+	}{Vendor: "acme"})
+	if err != nil {
+		t.Fatalf("CountProduct() error = %v", err)
+	}
+	if count != 3 {
+		t.Errorf("CountProduct() = %d, want 3", count)
+	}
+}
+
+func TestRetryAfterThrottling(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	client := newTestClient(t, server, shopify.WithRetryPolicy(shopify.RetryPolicy{
+		MaxRetries: 1,
+		MinBackoff: time.Millisecond,
+		MaxBackoff: time.Millisecond,
+	}))
+	ctx := context.Background()
+	accessToken := server.IssueAccessToken()
+
+	server.ThrottleNext(1)
+	start := time.Now()
+	if _, err := client.CountProduct(ctx, server.Shop, accessToken, nil); err != nil {
+		t.Fatalf("CountProduct() error = %v", err)
+	}
+
+	// the server asks for a second with Retry-After, longer than the backoff
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("CountProduct() retried after %s, want at least the Retry-After delay", elapsed)
+	}
+	if requests := len(server.Requests()); requests != 2 {
+		t.Errorf("server received %d requests, want 2", requests)
+	}
+
+	server.ThrottleNext(2)
+	if _, err := client.CountProduct(ctx, server.Shop, accessToken, nil); !shopify.IsRateLimited(err) {
+		t.Errorf("CountProduct() error = %v, want 429 once the retries are exhausted", err)
+	}
+}
+
+func TestVerifyWebhook(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+
+	body := []byte(`{"id":1,"title":"product"}`)
+	signature := server.SignWebhook(body)
+
+	if ok, err := shopify.VerifyWebhook(body, signature, testAPISecret); err != nil || !ok {
+		t.Errorf("VerifyWebhook() = %v, %v, want true", ok, err)
+	}
+	if ok, _ := shopify.VerifyWebhook([]byte(`{"id":2}`), signature, testAPISecret); ok {
+		t.Error("VerifyWebhook() accepted a tampered body")
+	}
+	if ok, _ := shopify.VerifyWebhook(body, signature, "other-secret"); ok {
+		t.Error("VerifyWebhook() accepted another secret")
+	}
+}
+
+func TestRetryThrottledCreate(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	client := newTestClient(t, server)
+	ctx := context.Background()
+	accessToken := server.IssueAccessToken()
+
+	// a throttled request was not processed, it is safe to send it again
+	server.ThrottleNext(1)
+	created, err := client.CreateProduct(ctx, server.Shop, accessToken, shopify.Product{Title: "product"})
+	if err != nil {
+		t.Fatalf("CreateProduct() error = %v", err)
+	}
+
+	products := server.Products()
+	if len(products) != 1 || products[0].ID != created.ID {
+		t.Errorf("server products = %+v, want only product %d", products, created.ID)
+	}
+}
@@ -0,0 +1,40 @@
+package shopify
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/rs/zerolog"
+)
+
+type contextKey int
+
+const (
+	shopContextKey contextKey = iota
+)
+
+func contextWithShop(ctx context.Context, shop string) context.Context {
+	return context.WithValue(ctx, shopContextKey, shop)
+}
+
+// requestShop returns the shop of a request created by the client, or its
+// host for requests created with NewRequest.
+func requestShop(request *http.Request) string {
+	if shop, ok := request.Context().Value(shopContextKey).(string); ok {
+		return shop
+	}
+
+	return request.URL.Host
+}
+
+// requestLogger returns the logger of the request context, which is expected
+// to carry the shop already, or the logger of the client with the shop of
+// the request.
+func (c *client) requestLogger(request *http.Request) zerolog.Logger {
+	logger := zerolog.Ctx(request.Context())
+	if logger != zerolog.DefaultContextLogger && logger.GetLevel() != zerolog.Disabled {
+		return *logger
+	}
+
+	return c.logger.With().Str("shop", requestShop(request)).Logger()
+}
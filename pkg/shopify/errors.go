@@ -0,0 +1,192 @@
+package shopify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RequestIdHeader identifies a request in the logs of Shopify, it should be
+// given when reporting an issue.
+const RequestIdHeader = "X-Request-Id"
+
+const (
+	// baseErrorKey holds the errors that are not tied to a field.
+	baseErrorKey = "base"
+
+	maxErrorBodySize = 1 << 20
+)
+
+// APIError is returned for every non-2xx response of the Shopify API.
+type APIError struct {
+	StatusCode int
+	RequestID  string
+	// Message is a human readable summary of Errors.
+	Message string
+	// Errors holds the errors of the response keyed by field, errors that are
+	// not tied to a field are stored under "base".
+	Errors     map[string][]string
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = http.StatusText(e.StatusCode)
+	}
+
+	if e.RequestID == "" {
+		return fmt.Sprintf("shopify: status %d: %s", e.StatusCode, message)
+	}
+
+	return fmt.Sprintf("shopify: status %d (request id %s): %s", e.StatusCode, e.RequestID, message)
+}
+
+// Retryable reports whether sending the same request again may succeed.
+func (e *APIError) Retryable() bool {
+	return isRetryable(e.StatusCode)
+}
+
+type errorResponse struct {
+	Errors           json.RawMessage `json:"errors"`
+	Error            string          `json:"error"`
+	ErrorDescription string          `json:"error_description"`
+}
+
+func newAPIError(res *http.Response) *APIError {
+	apiError := &APIError{
+		StatusCode: res.StatusCode,
+		RequestID:  res.Header.Get(RequestIdHeader),
+		Errors:     map[string][]string{},
+		RetryAfter: parseRetryAfter(res.Header.Get(retryAfterHeader)),
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxErrorBodySize))
+	if err != nil || len(body) == 0 {
+		return apiError
+	}
+
+	var response errorResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		// not every error page is json, e.g. some 5xx served by the edge
+		apiError.Message = strings.TrimSpace(string(body))
+		return apiError
+	}
+
+	if response.Error != "" {
+		apiError.Errors[baseErrorKey] = []string{response.Error}
+		if response.ErrorDescription != "" {
+			apiError.Errors[baseErrorKey] = append(apiError.Errors[baseErrorKey], response.ErrorDescription)
+		}
+	}
+
+	if len(response.Errors) > 0 {
+		parseErrors(response.Errors, apiError.Errors)
+	}
+
+	apiError.Message = formatErrors(apiError.Errors)
+
+	return apiError
+}
+
+// parseErrors decodes the "errors" field of a response which can either be a
+// string, a list of strings or a map of field to string or list of strings.
+func parseErrors(raw json.RawMessage, result map[string][]string) {
+	var message string
+	if err := json.Unmarshal(raw, &message); err == nil {
+		result[baseErrorKey] = append(result[baseErrorKey], message)
+		return
+	}
+
+	var messages []string
+	if err := json.Unmarshal(raw, &messages); err == nil {
+		result[baseErrorKey] = append(result[baseErrorKey], messages...)
+		return
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err == nil {
+		for field, value := range fields {
+			if err := json.Unmarshal(value, &message); err == nil {
+				result[field] = append(result[field], message)
+				continue
+			}
+
+			if err := json.Unmarshal(value, &messages); err == nil {
+				result[field] = append(result[field], messages...)
+				continue
+			}
+
+			result[field] = append(result[field], string(value))
+		}
+		return
+	}
+
+	result[baseErrorKey] = append(result[baseErrorKey], string(raw))
+}
+
+func formatErrors(errs map[string][]string) string {
+	fields := make([]string, 0, len(errs))
+	for field := range errs {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		message := strings.Join(errs[field], ", ")
+		if field != baseErrorKey {
+			message = field + ": " + message
+		}
+		parts = append(parts, message)
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+func hasStatus(err error, statusCode int) bool {
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+
+	return apiError.StatusCode == statusCode
+}
+
+// IsBadRequest reports whether err is an APIError with status 400, e.g. when
+// a session token is refused by a token exchange.
+func IsBadRequest(err error) bool {
+	return hasStatus(err, http.StatusBadRequest)
+}
+
+// IsNotFound reports whether err is an APIError with status 404.
+func IsNotFound(err error) bool {
+	return hasStatus(err, http.StatusNotFound)
+}
+
+// IsUnauthorized reports whether err is an APIError with status 401, which
+// usually means the access token was revoked.
+func IsUnauthorized(err error) bool {
+	return hasStatus(err, http.StatusUnauthorized)
+}
+
+// IsForbidden reports whether err is an APIError with status 403, which
+// usually means a scope is missing.
+func IsForbidden(err error) bool {
+	return hasStatus(err, http.StatusForbidden)
+}
+
+// IsRateLimited reports whether err is an APIError with status 429.
+func IsRateLimited(err error) bool {
+	return hasStatus(err, http.StatusTooManyRequests)
+}
+
+// IsUnprocessable reports whether err is an APIError with status 422.
+func IsUnprocessable(err error) bool {
+	return hasStatus(err, http.StatusUnprocessableEntity)
+}
@@ -0,0 +1,91 @@
+package shopify
+
+import (
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewAPIError(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantErrors  map[string][]string
+		wantMessage string
+	}{
+		{
+			name:        "string",
+			body:        `{"errors":"Not Found"}`,
+			wantErrors:  map[string][]string{"base": {"Not Found"}},
+			wantMessage: "Not Found",
+		},
+		{
+			name:        "array",
+			body:        `{"errors":["Invalid API key","Access denied"]}`,
+			wantErrors:  map[string][]string{"base": {"Invalid API key", "Access denied"}},
+			wantMessage: "Invalid API key, Access denied",
+		},
+		{
+			name:        "object",
+			body:        `{"errors":{"title":["can't be blank"],"handle":"is taken","base":["product is invalid"]}}`,
+			wantErrors:  map[string][]string{"title": {"can't be blank"}, "handle": {"is taken"}, "base": {"product is invalid"}},
+			wantMessage: "product is invalid; handle: is taken; title: can't be blank",
+		},
+		{
+			name:        "oauth",
+			body:        `{"error":"invalid_request","error_description":"The code is invalid"}`,
+			wantErrors:  map[string][]string{"base": {"invalid_request", "The code is invalid"}},
+			wantMessage: "invalid_request, The code is invalid",
+		},
+		{
+			name:        "not json",
+			body:        "<html>Bad Gateway</html>\n",
+			wantErrors:  map[string][]string{},
+			wantMessage: "<html>Bad Gateway</html>",
+		},
+		{
+			name:       "empty",
+			body:       "",
+			wantErrors: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := &http.Response{
+				StatusCode: http.StatusUnprocessableEntity,
+				Header:     http.Header{RequestIdHeader: {"request-1"}},
+				Body:       io.NopCloser(strings.NewReader(tt.body)),
+			}
+
+			got := newAPIError(res)
+			if !reflect.DeepEqual(got.Errors, tt.wantErrors) {
+				t.Errorf("newAPIError() errors = %v, want %v", got.Errors, tt.wantErrors)
+			}
+			if got.Message != tt.wantMessage {
+				t.Errorf("newAPIError() message = %q, want %q", got.Message, tt.wantMessage)
+			}
+			if got.StatusCode != http.StatusUnprocessableEntity || got.RequestID != "request-1" {
+				t.Errorf("newAPIError() = %+v, want the status and request id of the response", got)
+			}
+		})
+	}
+}
+
+func TestAPIErrorError(t *testing.T) {
+	err := &APIError{StatusCode: http.StatusNotFound}
+	if got := err.Error(); got != "shopify: status 404: Not Found" {
+		t.Errorf("Error() = %q", got)
+	}
+
+	err = &APIError{StatusCode: http.StatusTooManyRequests, RequestID: "request-1", Message: "Exceeded 2 calls per second", RetryAfter: time.Second}
+	if got := err.Error(); got != "shopify: status 429 (request id request-1): Exceeded 2 calls per second" {
+		t.Errorf("Error() = %q", got)
+	}
+	if !err.Retryable() {
+		t.Error("Retryable() of a 429 = false, want true")
+	}
+}
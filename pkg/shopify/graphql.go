@@ -0,0 +1,268 @@
+package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	graphqlPath = "graphql.json"
+
+	throttledErrorCode = "THROTTLED"
+)
+
+type GraphQLService interface {
+	// GraphQL sends query with variables and decodes its data into response.
+	GraphQL(ctx context.Context, shop string, accessToken string, query string, variables map[string]interface{}, response interface{}) error
+	// RegisterOperation stores a query or mutation under name so it can be
+	// sent with ExecuteOperation.
+	RegisterOperation(name string, query string) error
+	ExecuteOperation(ctx context.Context, shop string, accessToken string, name string, variables map[string]interface{}, response interface{}) error
+}
+
+type GraphQLRequest struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+type GraphQLResponse struct {
+	Data       json.RawMessage    `json:"data"`
+	Errors     GraphQLErrors      `json:"errors,omitempty"`
+	Extensions *GraphQLExtensions `json:"extensions,omitempty"`
+}
+
+type GraphQLExtensions struct {
+	Cost *GraphQLCost `json:"cost,omitempty"`
+}
+
+type GraphQLCost struct {
+	RequestedQueryCost float64               `json:"requestedQueryCost"`
+	ActualQueryCost    *float64              `json:"actualQueryCost"`
+	ThrottleStatus     GraphQLThrottleStatus `json:"throttleStatus"`
+}
+
+type GraphQLThrottleStatus struct {
+	MaximumAvailable   float64 `json:"maximumAvailable"`
+	CurrentlyAvailable float64 `json:"currentlyAvailable"`
+	RestoreRate        float64 `json:"restoreRate"`
+}
+
+type GraphQLErrorLocation struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// GraphQLError is a top level error of a GraphQL response, e.g. a syntax
+// error or a throttled query.
+type GraphQLError struct {
+	Message    string                 `json:"message"`
+	Locations  []GraphQLErrorLocation `json:"locations,omitempty"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func (e GraphQLError) Code() string {
+	code, _ := e.Extensions["code"].(string)
+	return code
+}
+
+type GraphQLErrors []GraphQLError
+
+func (e GraphQLErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		messages = append(messages, err.Message)
+	}
+
+	return "shopify: graphql: " + strings.Join(messages, "; ")
+}
+
+func (e GraphQLErrors) IsThrottled() bool {
+	for _, err := range e {
+		if err.Code() == throttledErrorCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// UserError is a validation error returned by a mutation in its userErrors
+// field.
+type UserError struct {
+	Field   []string `json:"field,omitempty"`
+	Message string   `json:"message"`
+	Code    string   `json:"code,omitempty"`
+}
+
+type UserErrors []UserError
+
+func (e UserErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for _, err := range e {
+		if len(err.Field) > 0 {
+			messages = append(messages, strings.Join(err.Field, ".")+": "+err.Message)
+		} else {
+			messages = append(messages, err.Message)
+		}
+	}
+
+	return "shopify: graphql user errors: " + strings.Join(messages, "; ")
+}
+
+// IsThrottled reports whether err is a GraphQL error caused by the cost
+// based throttling.
+func IsThrottled(err error) bool {
+	var graphqlErrors GraphQLErrors
+	if !errors.As(err, &graphqlErrors) {
+		return false
+	}
+
+	return graphqlErrors.IsThrottled()
+}
+
+type operationRegistry struct {
+	mutex      sync.RWMutex
+	operations map[string]string
+}
+
+func (c *client) RegisterOperation(name string, query string) error {
+	if name == "" || query == "" {
+		return errors.New("operation name and query are required")
+	}
+
+	c.operations.mutex.Lock()
+	defer c.operations.mutex.Unlock()
+
+	if _, ok := c.operations.operations[name]; ok {
+		return fmt.Errorf("operation %s is already registered", name)
+	}
+	c.operations.operations[name] = query
+
+	return nil
+}
+
+func (c *client) ExecuteOperation(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	name string,
+	variables map[string]interface{},
+	response interface{},
+) error {
+	c.operations.mutex.RLock()
+	query, ok := c.operations.operations[name]
+	c.operations.mutex.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("operation %s is not registered", name)
+	}
+
+	return c.sendGraphQL(ctx, shop, accessToken, GraphQLRequest{
+		Query:         query,
+		Variables:     variables,
+		OperationName: name,
+	}, response)
+}
+
+func (c *client) GraphQL(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	query string,
+	variables map[string]interface{},
+	response interface{},
+) error {
+	return c.sendGraphQL(ctx, shop, accessToken, GraphQLRequest{
+		Query:     query,
+		Variables: variables,
+	}, response)
+}
+
+func (c *client) sendGraphQL(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	request GraphQLRequest,
+	response interface{},
+) error {
+	requestUrl, err := c.createUrl(shop, graphqlPath)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if err := c.costLimiter.wait(ctx, shop, request.Query); err != nil {
+			return err
+		}
+
+		req, err := c.newRequest(ctx, shop, "POST", requestUrl, accessToken, request)
+		if err != nil {
+			return err
+		}
+
+		result := new(GraphQLResponse)
+		err = c.SendRequest(req, result)
+		if err != nil {
+			return err
+		}
+
+		if result.Extensions != nil && result.Extensions.Cost != nil {
+			c.costLimiter.update(shop, request.Query, *result.Extensions.Cost)
+		}
+
+		if result.Errors.IsThrottled() && attempt < c.retryPolicy.MaxRetries {
+			continue
+		}
+
+		return decodeGraphQLResponse(result, response)
+	}
+}
+
+func decodeGraphQLResponse(result *GraphQLResponse, response interface{}) error {
+	if len(result.Errors) > 0 {
+		return result.Errors
+	}
+
+	if response != nil && len(result.Data) > 0 {
+		if err := json.Unmarshal(result.Data, response); err != nil {
+			return err
+		}
+	}
+
+	if userErrors := findUserErrors(result.Data); len(userErrors) > 0 {
+		return userErrors
+	}
+
+	return nil
+}
+
+// findUserErrors collects the userErrors of every mutation in data.
+func findUserErrors(data json.RawMessage) UserErrors {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil
+	}
+
+	var result UserErrors
+	for _, field := range fields {
+		var payload struct {
+			UserErrors UserErrors `json:"userErrors"`
+		}
+		if err := json.Unmarshal(field, &payload); err != nil {
+			continue
+		}
+		result = append(result, payload.UserErrors...)
+	}
+
+	return result
+}
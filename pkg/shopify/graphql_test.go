@@ -0,0 +1,80 @@
+package shopify_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify/shopifytest"
+)
+
+const shopQuery = "{ shop { name } }"
+
+func stubShopQuery(server *shopifytest.Server) {
+	server.StubGraphQL("shop", func(request shopify.GraphQLRequest) (interface{}, shopify.GraphQLErrors) {
+		return map[string]interface{}{"shop": map[string]string{"name": "Example"}}, nil
+	})
+}
+
+func graphqlRequests(server *shopifytest.Server) int {
+	count := 0
+	for _, r := range server.Requests() {
+		if strings.HasSuffix(r.URL.Path, "/graphql.json") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGraphQLThrottledRetry(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	// restored in 200ms at the 50 points per second of the fake store
+	server.GraphQLCost = 10
+	stubShopQuery(server)
+	client := newTestClient(t, server)
+	accessToken := server.IssueAccessToken()
+
+	server.ThrottleNext(1)
+
+	var response struct {
+		Shop struct {
+			Name string `json:"name"`
+		} `json:"shop"`
+	}
+	start := time.Now()
+	if err := client.GraphQL(context.Background(), server.Shop, accessToken, shopQuery, nil, &response); err != nil {
+		t.Fatalf("GraphQL() error = %v", err)
+	}
+
+	if response.Shop.Name != "Example" {
+		t.Errorf("GraphQL() response = %+v, want the shop", response)
+	}
+	if requests := graphqlRequests(server); requests != 2 {
+		t.Errorf("GraphQL() sent %d requests, want 2", requests)
+	}
+	// the retry waits for the cost to be restored
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("GraphQL() retried after %s, want about 200ms", elapsed)
+	}
+}
+
+func TestGraphQLThrottledGivesUp(t *testing.T) {
+	server := shopifytest.NewServer(testAPIKey, testAPISecret)
+	defer server.Close()
+	stubShopQuery(server)
+	client := newTestClient(t, server, shopify.WithRetryPolicy(shopify.RetryPolicy{MaxRetries: 1}))
+	accessToken := server.IssueAccessToken()
+
+	server.ThrottleNext(5)
+
+	err := client.GraphQL(context.Background(), server.Shop, accessToken, shopQuery, nil, nil)
+	if !shopify.IsThrottled(err) {
+		t.Errorf("GraphQL() error = %v, want a throttled error", err)
+	}
+	if requests := graphqlRequests(server); requests != 2 {
+		t.Errorf("GraphQL() sent %d requests, want 2", requests)
+	}
+}
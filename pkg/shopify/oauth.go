@@ -1,18 +1,46 @@
 package shopify
 
 import (
+	"context"
 	"encoding/json"
-	"net/url"
+)
+
+// TokenType is the requested_token_type of a token exchange.
+type TokenType string
+
+const (
+	// OfflineAccessToken is the access token of the shop, it never expires.
+	OfflineAccessToken TokenType = "urn:shopify:params:oauth:token-type:offline-access-token"
+	// OnlineAccessToken is the access token of the user of the session token,
+	// it expires along with the user session.
+	OnlineAccessToken TokenType = "urn:shopify:params:oauth:token-type:online-access-token"
+)
+
+// TokenExchangeGrantType and IdTokenType are the grant_type and the
+// subject_token_type of a token exchange.
+const (
+	TokenExchangeGrantType = "urn:ietf:params:oauth:grant-type:token-exchange"
+	IdTokenType            = "urn:ietf:params:oauth:token-type:id_token"
 )
 
 type OauthService interface {
-	GetAccessToken(shop string, code string) (*TokenResponse, error)
+	GetAccessToken(ctx context.Context, shop string, code string) (*TokenResponse, error)
+	// ExchangeToken exchanges the session token of an embedded app for an
+	// access token, without redirecting the user through OAuth.
+	ExchangeToken(ctx context.Context, shop string, sessionToken string, tokenType TokenType) (*TokenResponse, error)
+	// ListAccessScopes returns the scopes granted to accessToken. It fails
+	// with 401 once the token was revoked, e.g. by an uninstall.
+	ListAccessScopes(ctx context.Context, shop string, accessToken string) ([]string, error)
 }
 
 type TokenRequest struct {
-	ClientId     string `json:"client_id"`
-	ClientSecret string `json:"client_secret"`
-	Code         string `json:"code"`
+	ClientId           string    `json:"client_id"`
+	ClientSecret       string    `json:"client_secret"`
+	Code               string    `json:"code,omitempty"`
+	GrantType          string    `json:"grant_type,omitempty"`
+	SubjectToken       string    `json:"subject_token,omitempty"`
+	SubjectTokenType   string    `json:"subject_token_type,omitempty"`
+	RequestedTokenType TokenType `json:"requested_token_type,omitempty"`
 }
 
 func (r *TokenRequest) ToBytes() []byte {
@@ -23,23 +51,93 @@ func (r *TokenRequest) ToBytes() []byte {
 type TokenResponse struct {
 	AccessToken string `json:"access_token"`
 	Scope       string `json:"scope"`
+
+	// online access tokens only
+	ExpiresIn           int             `json:"expires_in,omitempty"`
+	AssociatedUserScope string          `json:"associated_user_scope,omitempty"`
+	AssociatedUser      *AssociatedUser `json:"associated_user,omitempty"`
 }
 
-func (c *client) GetAccessToken(shop string, code string) (*TokenResponse, error) {
-	requestUrl, err := url.Parse("https://" + shop)
-	if err != nil {
-		return nil, err
-	}
+type AccessScope struct {
+	Handle string `json:"handle"`
+}
 
-	requestUrl.Path = "/admin/oauth/access_token"
+type AccessScopesResource struct {
+	AccessScopes []AccessScope `json:"access_scopes"`
+}
+
+// AssociatedUser is the staff member an online access token was issued for.
+type AssociatedUser struct {
+	ID            int64  `json:"id"`
+	FirstName     string `json:"first_name"`
+	LastName      string `json:"last_name"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	AccountOwner  bool   `json:"account_owner"`
+	Locale        string `json:"locale"`
+	Collaborator  bool   `json:"collaborator"`
+}
 
-	data := &TokenRequest{
+func (c *client) GetAccessToken(ctx context.Context, shop string, code string) (*TokenResponse, error) {
+	return c.requestAccessToken(ctx, shop, &TokenRequest{
 		ClientId:     c.apiKey,
 		ClientSecret: c.apiSecret,
 		Code:         code,
+	})
+}
+
+func (c *client) ExchangeToken(
+	ctx context.Context,
+	shop string,
+	sessionToken string,
+	tokenType TokenType,
+) (*TokenResponse, error) {
+	return c.requestAccessToken(ctx, shop, &TokenRequest{
+		ClientId:           c.apiKey,
+		ClientSecret:       c.apiSecret,
+		GrantType:          TokenExchangeGrantType,
+		SubjectToken:       sessionToken,
+		SubjectTokenType:   IdTokenType,
+		RequestedTokenType: tokenType,
+	})
+}
+
+func (c *client) ListAccessScopes(ctx context.Context, shop string, accessToken string) ([]string, error) {
+	baseUrl, err := c.baseURLResolver(shop)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := NewRequest("POST", requestUrl, "", data)
+	requestUrl := baseUrl.JoinPath("admin/oauth/access_scopes.json")
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(AccessScopesResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := make([]string, 0, len(result.AccessScopes))
+	for _, scope := range result.AccessScopes {
+		scopes = append(scopes, scope.Handle)
+	}
+
+	return scopes, nil
+}
+
+func (c *client) requestAccessToken(ctx context.Context, shop string, data *TokenRequest) (*TokenResponse, error) {
+	baseUrl, err := c.baseURLResolver(shop)
+	if err != nil {
+		return nil, err
+	}
+
+	requestUrl := baseUrl.JoinPath("admin/oauth/access_token")
+
+	req, err := c.newRequest(ctx, shop, "POST", requestUrl, "", data)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,117 @@
+package shopify
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ListOptions holds the parameters shared by every list endpoint. Shopify
+// only accepts Limit and Fields alongside PageInfo, so the options returned in
+// a Pagination never carry resource specific filters.
+type ListOptions struct {
+	PageInfo string `url:"page_info,omitempty"`
+	Limit    int    `url:"limit,omitempty"`
+	SinceID  int64  `url:"since_id,omitempty"`
+	Fields   string `url:"fields,omitempty"`
+}
+
+// setOptions encodes options into the query string of u. options must be a
+// struct, or a pointer to one, whose fields are tagged with `url:"name"`.
+func setOptions(u *url.URL, options interface{}) error {
+	if options == nil {
+		return nil
+	}
+
+	v := reflect.ValueOf(options)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("options must be a struct, got %s", v.Kind())
+	}
+
+	query := u.Query()
+	if err := encodeOptions(query, v); err != nil {
+		return err
+	}
+	u.RawQuery = query.Encode()
+
+	return nil
+}
+
+func encodeOptions(query url.Values, v reflect.Value) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		value := v.Field(i)
+
+		if field.Anonymous && value.Kind() == reflect.Struct {
+			if err := encodeOptions(query, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		tag := field.Tag.Get("url")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(tag, ",")
+		if opts == "omitempty" && value.IsZero() {
+			continue
+		}
+
+		encoded, err := encodeValue(value)
+		if err != nil {
+			return fmt.Errorf("failed to encode option %s: %w", name, err)
+		}
+		query.Set(name, encoded)
+	}
+
+	return nil
+}
+
+func encodeValue(v reflect.Value) (string, error) {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "", nil
+		}
+		v = v.Elem()
+	}
+
+	if t, ok := v.Interface().(time.Time); ok {
+		return t.Format(time.RFC3339), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Slice:
+		values := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			value, err := encodeValue(v.Index(i))
+			if err != nil {
+				return "", err
+			}
+			values = append(values, value)
+		}
+		return strings.Join(values, ","), nil
+	}
+
+	return "", fmt.Errorf("unsupported kind %s", v.Kind())
+}
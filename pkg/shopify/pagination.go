@@ -0,0 +1,153 @@
+package shopify
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+)
+
+// linkRegex matches every link of a Link header. The header cannot be split
+// on commas, the fields parameter of the links may contain unescaped ones.
+var linkRegex = regexp.MustCompile(`<([^>]*)>\s*;\s*rel="(previous|next)"`)
+
+// Pagination holds the options to request the pages around the current one,
+// as advertised by the Link header of a list response. A nil NextPageOptions
+// means the current page is the last one.
+type Pagination struct {
+	NextPageOptions     *ListOptions
+	PreviousPageOptions *ListOptions
+}
+
+func parsePagination(linkHeader string) (*Pagination, error) {
+	pagination := new(Pagination)
+	if linkHeader == "" {
+		return pagination, nil
+	}
+
+	matches := linkRegex.FindAllStringSubmatch(linkHeader, -1)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("could not parse link header %q", linkHeader)
+	}
+
+	for _, match := range matches {
+		link := match[0]
+		linkUrl, err := url.Parse(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		query := linkUrl.Query()
+		options := &ListOptions{
+			PageInfo: query.Get("page_info"),
+			Fields:   query.Get("fields"),
+		}
+		if options.PageInfo == "" {
+			return nil, fmt.Errorf("missing page_info in link %q", link)
+		}
+
+		if limit := query.Get("limit"); limit != "" {
+			options.Limit, err = strconv.Atoi(limit)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if match[2] == "next" {
+			pagination.NextPageOptions = options
+		} else {
+			pagination.PreviousPageOptions = options
+		}
+	}
+
+	return pagination, nil
+}
+
+// PageFunc fetches a single page of a list endpoint.
+type PageFunc[T any] func(options interface{}) ([]T, *Pagination, error)
+
+// PageIterator walks a list endpoint page by page following the Link header.
+//
+//	it := shopify.NewPageIterator(fetch, &shopify.ProductOptions{})
+//	for it.Next() {
+//		for _, product := range it.Page() { ... }
+//	}
+//	if err := it.Err(); err != nil { ... }
+type PageIterator[T any] struct {
+	fetch   PageFunc[T]
+	options interface{}
+	page    []T
+	err     error
+	done    bool
+}
+
+func NewPageIterator[T any](fetch PageFunc[T], options interface{}) *PageIterator[T] {
+	return &PageIterator[T]{
+		fetch:   fetch,
+		options: options,
+	}
+}
+
+// Next fetches the next page and reports whether there was one.
+func (it *PageIterator[T]) Next() bool {
+	if it.done || it.err != nil {
+		return false
+	}
+
+	page, pagination, err := it.fetch(it.options)
+	if err != nil {
+		it.err = err
+		return false
+	}
+
+	it.page = page
+	if pagination == nil || pagination.NextPageOptions == nil {
+		it.done = true
+	} else {
+		it.options = pagination.NextPageOptions
+	}
+
+	return true
+}
+
+// Page returns the page fetched by the last call to Next.
+func (it *PageIterator[T]) Page() []T {
+	return it.page
+}
+
+// Options returns the options that will be used to fetch the next page,
+// which can be stored to resume the iteration later.
+func (it *PageIterator[T]) Options() interface{} {
+	return it.options
+}
+
+func (it *PageIterator[T]) Err() error {
+	return it.err
+}
+
+// ForEachPage calls fn with every page of the list endpoint, stopping at the
+// first error returned either by the endpoint or by fn.
+func ForEachPage[T any](fetch PageFunc[T], options interface{}, fn func(page []T) error) error {
+	it := NewPageIterator(fetch, options)
+	for it.Next() {
+		if err := fn(it.Page()); err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
+
+// ListAll collects every page of the list endpoint.
+func ListAll[T any](fetch PageFunc[T], options interface{}) ([]T, error) {
+	var results []T
+	err := ForEachPage(fetch, options, func(page []T) error {
+		results = append(results, page...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
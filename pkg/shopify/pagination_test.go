@@ -0,0 +1,82 @@
+package shopify
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		want    *Pagination
+		wantErr bool
+	}{
+		{
+			name:   "no link",
+			header: "",
+			want:   &Pagination{},
+		},
+		{
+			name:   "next only",
+			header: `<https://shop.myshopify.com/admin/api/2026-07/products.json?limit=50&page_info=abc>; rel="next"`,
+			want: &Pagination{
+				NextPageOptions: &ListOptions{PageInfo: "abc", Limit: 50},
+			},
+		},
+		{
+			name: "previous and next",
+			header: `<https://shop.myshopify.com/admin/api/2026-07/products.json?limit=50&page_info=prev>; rel="previous", ` +
+				`<https://shop.myshopify.com/admin/api/2026-07/products.json?limit=50&page_info=next>; rel="next"`,
+			want: &Pagination{
+				NextPageOptions:     &ListOptions{PageInfo: "next", Limit: 50},
+				PreviousPageOptions: &ListOptions{PageInfo: "prev", Limit: 50},
+			},
+		},
+		{
+			name: "fields with unescaped commas",
+			header: `<https://shop.myshopify.com/admin/api/2026-07/products.json?fields=id,title,handle&limit=3&page_info=prev>; rel="previous",` +
+				`<https://shop.myshopify.com/admin/api/2026-07/products.json?fields=id,title,handle&limit=3&page_info=next>; rel="next"`,
+			want: &Pagination{
+				NextPageOptions:     &ListOptions{PageInfo: "next", Limit: 3, Fields: "id,title,handle"},
+				PreviousPageOptions: &ListOptions{PageInfo: "prev", Limit: 3, Fields: "id,title,handle"},
+			},
+		},
+		{
+			name:    "missing page_info",
+			header:  `<https://shop.myshopify.com/admin/api/2026-07/products.json?limit=50>; rel="next"`,
+			wantErr: true,
+		},
+		{
+			name:    "invalid limit",
+			header:  `<https://shop.myshopify.com/admin/api/2026-07/products.json?limit=many&page_info=abc>; rel="next"`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed",
+			header:  `https://shop.myshopify.com/admin/api/2026-07/products.json; rel=next`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePagination(tt.header)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parsePagination() = %+v, want an error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parsePagination() error = %v", err)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parsePagination() next = %+v, previous = %+v, want next = %+v, previous = %+v",
+					got.NextPageOptions, got.PreviousPageOptions, tt.want.NextPageOptions, tt.want.PreviousPageOptions)
+			}
+		})
+	}
+}
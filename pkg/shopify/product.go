@@ -1 +1,622 @@
 package shopify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	productsBasePath = "products"
+	variantsBasePath = "variants"
+	imagesBasePath   = "images"
+)
+
+type ProductService interface {
+	ListProduct(ctx context.Context, shop string, accessToken string, options interface{}) ([]Product, error)
+	ListProductWithPagination(ctx context.Context, shop string, accessToken string, options interface{}) ([]Product, *Pagination, error)
+	CountProduct(ctx context.Context, shop string, accessToken string, options interface{}) (int, error)
+	GetProduct(ctx context.Context, shop string, accessToken string, id int64, options interface{}) (*Product, error)
+	CreateProduct(ctx context.Context, shop string, accessToken string, product Product) (*Product, error)
+	UpdateProduct(ctx context.Context, shop string, accessToken string, product Product) (*Product, error)
+	DeleteProduct(ctx context.Context, shop string, accessToken string, id int64) error
+
+	ListVariant(ctx context.Context, shop string, accessToken string, productId int64, options interface{}) ([]Variant, error)
+	ListVariantWithPagination(ctx context.Context, shop string, accessToken string, productId int64, options interface{}) ([]Variant, *Pagination, error)
+	CountVariant(ctx context.Context, shop string, accessToken string, productId int64, options interface{}) (int, error)
+	GetVariant(ctx context.Context, shop string, accessToken string, id int64, options interface{}) (*Variant, error)
+	CreateVariant(ctx context.Context, shop string, accessToken string, productId int64, variant Variant) (*Variant, error)
+	UpdateVariant(ctx context.Context, shop string, accessToken string, variant Variant) (*Variant, error)
+	DeleteVariant(ctx context.Context, shop string, accessToken string, productId int64, id int64) error
+
+	ListImage(ctx context.Context, shop string, accessToken string, productId int64, options interface{}) ([]Image, error)
+	CountImage(ctx context.Context, shop string, accessToken string, productId int64, options interface{}) (int, error)
+	GetImage(ctx context.Context, shop string, accessToken string, productId int64, id int64, options interface{}) (*Image, error)
+	CreateImage(ctx context.Context, shop string, accessToken string, productId int64, image Image) (*Image, error)
+	UpdateImage(ctx context.Context, shop string, accessToken string, productId int64, image Image) (*Image, error)
+	DeleteImage(ctx context.Context, shop string, accessToken string, productId int64, id int64) error
+}
+
+type Product struct {
+	ID                int64      `json:"id,omitempty"`
+	Title             string     `json:"title,omitempty"`
+	BodyHTML          string     `json:"body_html,omitempty"`
+	Vendor            string     `json:"vendor,omitempty"`
+	ProductType       string     `json:"product_type,omitempty"`
+	Handle            string     `json:"handle,omitempty"`
+	Status            string     `json:"status,omitempty"`
+	Tags              string     `json:"tags,omitempty"`
+	TemplateSuffix    string     `json:"template_suffix,omitempty"`
+	PublishedScope    string     `json:"published_scope,omitempty"`
+	AdminGraphqlApiId string     `json:"admin_graphql_api_id,omitempty"`
+	Options           []Option   `json:"options,omitempty"`
+	Variants          []Variant  `json:"variants,omitempty"`
+	Images            []Image    `json:"images,omitempty"`
+	Image             *Image     `json:"image,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+	PublishedAt       *time.Time `json:"published_at,omitempty"`
+}
+
+type Option struct {
+	ID        int64    `json:"id,omitempty"`
+	ProductID int64    `json:"product_id,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Position  int      `json:"position,omitempty"`
+	Values    []string `json:"values,omitempty"`
+}
+
+// Variant is a variant of a product. CompareAtPrice, Taxable and
+// RequiresShipping are pointers so that an update can clear or unset them:
+// nil leaves the field unchanged, while an empty CompareAtPrice removes the
+// compare at price.
+type Variant struct {
+	ID                  int64      `json:"id,omitempty"`
+	ProductID           int64      `json:"product_id,omitempty"`
+	Title               string     `json:"title,omitempty"`
+	Sku                 string     `json:"sku,omitempty"`
+	Barcode             string     `json:"barcode,omitempty"`
+	Price               string     `json:"price,omitempty"`
+	CompareAtPrice      *string    `json:"compare_at_price,omitempty"`
+	Position            int        `json:"position,omitempty"`
+	Option1             string     `json:"option1,omitempty"`
+	Option2             string     `json:"option2,omitempty"`
+	Option3             string     `json:"option3,omitempty"`
+	Taxable             *bool      `json:"taxable,omitempty"`
+	InventoryPolicy     string     `json:"inventory_policy,omitempty"`
+	InventoryQuantity   int        `json:"inventory_quantity,omitempty"`
+	InventoryManagement string     `json:"inventory_management,omitempty"`
+	InventoryItemID     int64      `json:"inventory_item_id,omitempty"`
+	FulfillmentService  string     `json:"fulfillment_service,omitempty"`
+	RequiresShipping    *bool      `json:"requires_shipping,omitempty"`
+	Grams               int        `json:"grams,omitempty"`
+	Weight              float64    `json:"weight,omitempty"`
+	WeightUnit          string     `json:"weight_unit,omitempty"`
+	ImageID             int64      `json:"image_id,omitempty"`
+	AdminGraphqlApiId   string     `json:"admin_graphql_api_id,omitempty"`
+	CreatedAt           *time.Time `json:"created_at,omitempty"`
+	UpdatedAt           *time.Time `json:"updated_at,omitempty"`
+}
+
+type Image struct {
+	ID                int64      `json:"id,omitempty"`
+	ProductID         int64      `json:"product_id,omitempty"`
+	Position          int        `json:"position,omitempty"`
+	Src               string     `json:"src,omitempty"`
+	Attachment        string     `json:"attachment,omitempty"`
+	Filename          string     `json:"filename,omitempty"`
+	Alt               string     `json:"alt,omitempty"`
+	Width             int        `json:"width,omitempty"`
+	Height            int        `json:"height,omitempty"`
+	VariantIds        []int64    `json:"variant_ids,omitempty"`
+	AdminGraphqlApiId string     `json:"admin_graphql_api_id,omitempty"`
+	CreatedAt         *time.Time `json:"created_at,omitempty"`
+	UpdatedAt         *time.Time `json:"updated_at,omitempty"`
+}
+
+type ProductResource struct {
+	Product *Product `json:"product"`
+}
+
+func (r *ProductResource) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type ProductResources struct {
+	Products []Product `json:"products"`
+}
+
+func (r *ProductResources) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type VariantResource struct {
+	Variant *Variant `json:"variant"`
+}
+
+func (r *VariantResource) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type VariantResources struct {
+	Variants []Variant `json:"variants"`
+}
+
+func (r *VariantResources) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type ImageResource struct {
+	Image *Image `json:"image"`
+}
+
+func (r *ImageResource) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type ImageResources struct {
+	Images []Image `json:"images"`
+}
+
+func (r *ImageResources) ToBytes() []byte {
+	result, _ := json.Marshal(r)
+	return result
+}
+
+type countResource struct {
+	Count int `json:"count"`
+}
+
+// ProductOptions can be used for filtering products on a List or Count request.
+type ProductOptions struct {
+	ListOptions
+	Ids             string     `url:"ids,omitempty"`
+	Title           string     `url:"title,omitempty"`
+	Vendor          string     `url:"vendor,omitempty"`
+	Handle          string     `url:"handle,omitempty"`
+	ProductType     string     `url:"product_type,omitempty"`
+	Status          string     `url:"status,omitempty"`
+	CollectionID    int64      `url:"collection_id,omitempty"`
+	CreatedAtMin    *time.Time `url:"created_at_min,omitempty"`
+	CreatedAtMax    *time.Time `url:"created_at_max,omitempty"`
+	UpdatedAtMin    *time.Time `url:"updated_at_min,omitempty"`
+	UpdatedAtMax    *time.Time `url:"updated_at_max,omitempty"`
+	PublishedStatus string     `url:"published_status,omitempty"`
+}
+
+// VariantOptions can be used for filtering variants on a List request.
+type VariantOptions struct {
+	ListOptions
+	PresentmentCurrencies string `url:"presentment_currencies,omitempty"`
+}
+
+// ListProduct returns the products of every page.
+func (c *client) ListProduct(ctx context.Context, shop string, accessToken string, options interface{}) ([]Product, error) {
+	return ListAll(func(options interface{}) ([]Product, *Pagination, error) {
+		return c.ListProductWithPagination(ctx, shop, accessToken, options)
+	}, options)
+}
+
+func (c *client) ListProductWithPagination(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	options interface{},
+) ([]Product, *Pagination, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s.json", productsBasePath))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := new(ProductResources)
+	pagination, err := c.SendRequestWithPagination(req, result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result.Products, pagination, nil
+}
+
+func (c *client) CountProduct(ctx context.Context, shop string, accessToken string, options interface{}) (int, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/count.json", productsBasePath))
+	if err != nil {
+		return 0, err
+	}
+
+	return c.count(ctx, shop, requestUrl, accessToken, options)
+}
+
+func (c *client) GetProduct(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	id int64,
+	options interface{},
+) (*Product, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", productsBasePath, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ProductResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Product, nil
+}
+
+func (c *client) CreateProduct(ctx context.Context, shop string, accessToken string, product Product) (*Product, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s.json", productsBasePath))
+	if err != nil {
+		return nil, err
+	}
+
+	request := ProductResource{Product: &product}
+	req, err := c.newRequest(ctx, shop, "POST", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ProductResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Product, nil
+}
+
+func (c *client) UpdateProduct(ctx context.Context, shop string, accessToken string, product Product) (*Product, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", productsBasePath, product.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	request := ProductResource{Product: &product}
+	req, err := c.newRequest(ctx, shop, "PUT", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ProductResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Product, nil
+}
+
+func (c *client) DeleteProduct(ctx context.Context, shop string, accessToken string, id int64) error {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", productsBasePath, id))
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, shop, "DELETE", requestUrl, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.SendRequest(req, nil)
+}
+
+// ListVariant returns the variants of every page.
+func (c *client) ListVariant(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	options interface{},
+) ([]Variant, error) {
+	return ListAll(func(options interface{}) ([]Variant, *Pagination, error) {
+		return c.ListVariantWithPagination(ctx, shop, accessToken, productId, options)
+	}, options)
+}
+
+func (c *client) ListVariantWithPagination(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	options interface{},
+) ([]Variant, *Pagination, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s.json", productsBasePath, productId, variantsBasePath))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := new(VariantResources)
+	pagination, err := c.SendRequestWithPagination(req, result)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return result.Variants, pagination, nil
+}
+
+func (c *client) CountVariant(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	options interface{},
+) (int, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/count.json", productsBasePath, productId, variantsBasePath))
+	if err != nil {
+		return 0, err
+	}
+
+	return c.count(ctx, shop, requestUrl, accessToken, options)
+}
+
+func (c *client) GetVariant(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	id int64,
+	options interface{},
+) (*Variant, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", variantsBasePath, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(VariantResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Variant, nil
+}
+
+func (c *client) CreateVariant(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	variant Variant,
+) (*Variant, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s.json", productsBasePath, productId, variantsBasePath))
+	if err != nil {
+		return nil, err
+	}
+
+	request := VariantResource{Variant: &variant}
+	req, err := c.newRequest(ctx, shop, "POST", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(VariantResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Variant, nil
+}
+
+func (c *client) UpdateVariant(ctx context.Context, shop string, accessToken string, variant Variant) (*Variant, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", variantsBasePath, variant.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	request := VariantResource{Variant: &variant}
+	req, err := c.newRequest(ctx, shop, "PUT", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(VariantResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Variant, nil
+}
+
+func (c *client) DeleteVariant(ctx context.Context, shop string, accessToken string, productId int64, id int64) error {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/%d.json", productsBasePath, productId, variantsBasePath, id))
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, shop, "DELETE", requestUrl, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.SendRequest(req, nil)
+}
+
+func (c *client) ListImage(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	options interface{},
+) ([]Image, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s.json", productsBasePath, productId, imagesBasePath))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ImageResources)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Images, nil
+}
+
+func (c *client) CountImage(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	options interface{},
+) (int, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/count.json", productsBasePath, productId, imagesBasePath))
+	if err != nil {
+		return 0, err
+	}
+
+	return c.count(ctx, shop, requestUrl, accessToken, options)
+}
+
+func (c *client) GetImage(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	id int64,
+	options interface{},
+) (*Image, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/%d.json", productsBasePath, productId, imagesBasePath, id))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ImageResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Image, nil
+}
+
+func (c *client) CreateImage(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	image Image,
+) (*Image, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s.json", productsBasePath, productId, imagesBasePath))
+	if err != nil {
+		return nil, err
+	}
+
+	request := ImageResource{Image: &image}
+	req, err := c.newRequest(ctx, shop, "POST", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ImageResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Image, nil
+}
+
+func (c *client) UpdateImage(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	productId int64,
+	image Image,
+) (*Image, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/%d.json", productsBasePath, productId, imagesBasePath, image.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	request := ImageResource{Image: &image}
+	req, err := c.newRequest(ctx, shop, "PUT", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(ImageResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Image, nil
+}
+
+func (c *client) DeleteImage(ctx context.Context, shop string, accessToken string, productId int64, id int64) error {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d/%s/%d.json", productsBasePath, productId, imagesBasePath, id))
+	if err != nil {
+		return err
+	}
+
+	req, err := c.newRequest(ctx, shop, "DELETE", requestUrl, accessToken, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.SendRequest(req, nil)
+}
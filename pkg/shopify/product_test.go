@@ -0,0 +1,48 @@
+package shopify
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestVariantResourceToBytes(t *testing.T) {
+	empty := ""
+	unset := false
+
+	tests := []struct {
+		name    string
+		variant Variant
+		want    []string
+		omitted []string
+	}{
+		{
+			name:    "unchanged fields are omitted",
+			variant: Variant{ID: 1, Price: "10.00"},
+			want:    []string{`"price":"10.00"`},
+			omitted: []string{"compare_at_price", "taxable", "requires_shipping"},
+		},
+		{
+			name:    "fields set to their zero value are sent",
+			variant: Variant{ID: 1, CompareAtPrice: &empty, Taxable: &unset, RequiresShipping: &unset},
+			want:    []string{`"compare_at_price":""`, `"taxable":false`, `"requires_shipping":false`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource := VariantResource{Variant: &tt.variant}
+			body := string(resource.ToBytes())
+
+			for _, want := range tt.want {
+				if !strings.Contains(body, want) {
+					t.Errorf("ToBytes() = %s, want %s", body, want)
+				}
+			}
+			for _, omitted := range tt.omitted {
+				if strings.Contains(body, omitted) {
+					t.Errorf("ToBytes() = %s, want %s omitted", body, omitted)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package shopify
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const callLimitHeader = "X-Shopify-Shop-Api-Call-Limit"
+
+// RateLimit describes the REST Admin API leaky bucket of a shop: Capacity
+// requests can be burst, and RestoreRate requests leak out every second.
+type RateLimit struct {
+	Capacity    int
+	RestoreRate float64
+}
+
+var (
+	DefaultRateLimit = RateLimit{Capacity: 40, RestoreRate: 2}
+	PlusRateLimit    = RateLimit{Capacity: 80, RestoreRate: 4}
+)
+
+func (l RateLimit) validate() error {
+	if l.Capacity <= 0 {
+		return fmt.Errorf("rate limit capacity must be positive, got %d", l.Capacity)
+	}
+
+	if l.RestoreRate <= 0 || math.IsNaN(l.RestoreRate) || math.IsInf(l.RestoreRate, 0) {
+		return fmt.Errorf("rate limit restore rate must be positive, got %v", l.RestoreRate)
+	}
+
+	return nil
+}
+
+type bucket struct {
+	mutex     sync.Mutex
+	limit     RateLimit
+	level     float64
+	updatedAt time.Time
+}
+
+// leak drains the bucket for the time elapsed since the last update. The
+// caller must hold the mutex.
+func (b *bucket) leak(now time.Time) {
+	elapsed := now.Sub(b.updatedAt).Seconds()
+	b.level = math.Max(0, b.level-elapsed*b.limit.RestoreRate)
+	b.updatedAt = now
+}
+
+// wait blocks until a request fits in the bucket or ctx is done.
+func (b *bucket) wait(ctx context.Context) error {
+	for {
+		b.mutex.Lock()
+		b.leak(time.Now())
+		capacity := float64(b.limit.Capacity)
+		if b.level+1 <= capacity {
+			b.level++
+			b.mutex.Unlock()
+			return nil
+		}
+		delay := time.Duration((b.level + 1 - capacity) / b.limit.RestoreRate * float64(time.Second))
+		b.mutex.Unlock()
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// sync aligns the bucket with the usage reported by Shopify. The local level
+// is never lowered since it also accounts for requests still in flight.
+func (b *bucket) sync(used int, capacity int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.leak(time.Now())
+	if capacity > 0 && capacity != b.limit.Capacity {
+		// Shopify restores the bucket proportionally to its size, so a Plus
+		// shop reporting 80 requests gets twice the default restore rate.
+		b.limit.RestoreRate = b.limit.RestoreRate * float64(capacity) / float64(b.limit.Capacity)
+		b.limit.Capacity = capacity
+	}
+	b.level = math.Max(b.level, float64(used))
+}
+
+// fill marks the bucket as full after Shopify throttled a request.
+func (b *bucket) fill() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.leak(time.Now())
+	b.level = float64(b.limit.Capacity)
+}
+
+type rateLimiter struct {
+	mutex   sync.Mutex
+	limit   RateLimit
+	buckets map[string]*bucket
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	return &rateLimiter{
+		limit:   limit,
+		buckets: map[string]*bucket{},
+	}
+}
+
+func (l *rateLimiter) bucket(shop string) *bucket {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[shop]
+	if !ok {
+		b = &bucket{limit: l.limit, updatedAt: time.Now()}
+		l.buckets[shop] = b
+	}
+
+	return b
+}
+
+func (l *rateLimiter) setLimit(shop string, limit RateLimit) {
+	b := l.bucket(shop)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.limit = limit
+}
+
+func (l *rateLimiter) wait(ctx context.Context, shop string) error {
+	return l.bucket(shop).wait(ctx)
+}
+
+// update reads the call limit header of res, e.g. "32/40", and resyncs the
+// bucket of shop.
+func (l *rateLimiter) update(shop string, res *http.Response) {
+	if res.StatusCode == http.StatusTooManyRequests {
+		l.bucket(shop).fill()
+		return
+	}
+
+	used, capacity, ok := parseCallLimit(res.Header.Get(callLimitHeader))
+	if !ok {
+		return
+	}
+
+	l.bucket(shop).sync(used, capacity)
+}
+
+func parseCallLimit(header string) (int, int, bool) {
+	usedValue, capacityValue, found := strings.Cut(header, "/")
+	if !found {
+		return 0, 0, false
+	}
+
+	used, err := strconv.Atoi(strings.TrimSpace(usedValue))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	capacity, err := strconv.Atoi(strings.TrimSpace(capacityValue))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	return used, capacity, true
+}
+
+const (
+	// defaultQueryCost is the cost assumed for a query that was never sent.
+	defaultQueryCost = 10
+	maxKnownQueries  = 256
+)
+
+type costBucket struct {
+	mutex  sync.Mutex
+	status GraphQLThrottleStatus
+	known  bool
+	// available is status.CurrentlyAvailable minus the cost of the queries
+	// sent since, restored over time.
+	available float64
+	updatedAt time.Time
+}
+
+// costLimiter throttles GraphQL queries with the cost based bucket reported
+// in extensions.cost.throttleStatus of every response.
+type costLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*costBucket
+	costs   map[string]float64
+}
+
+func newCostLimiter() *costLimiter {
+	return &costLimiter{
+		buckets: map[string]*costBucket{},
+		costs:   map[string]float64{},
+	}
+}
+
+func (l *costLimiter) bucket(shop string) *costBucket {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, ok := l.buckets[shop]
+	if !ok {
+		b = &costBucket{updatedAt: time.Now()}
+		l.buckets[shop] = b
+	}
+
+	return b
+}
+
+func (l *costLimiter) estimate(query string) float64 {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if cost, ok := l.costs[query]; ok {
+		return cost
+	}
+
+	return defaultQueryCost
+}
+
+// wait blocks until the estimated cost of query is available or ctx is done.
+// Nothing is known about a shop before its first response, so the first
+// query is always sent right away.
+func (l *costLimiter) wait(ctx context.Context, shop string, query string) error {
+	cost := l.estimate(query)
+	b := l.bucket(shop)
+
+	for {
+		b.mutex.Lock()
+		if !b.known {
+			b.mutex.Unlock()
+			return nil
+		}
+
+		now := time.Now()
+		elapsed := now.Sub(b.updatedAt).Seconds()
+		b.available = math.Min(b.status.MaximumAvailable, b.available+elapsed*b.status.RestoreRate)
+		b.updatedAt = now
+
+		cost = math.Min(cost, b.status.MaximumAvailable)
+		if b.available >= cost || b.status.RestoreRate <= 0 {
+			b.available -= cost
+			b.mutex.Unlock()
+			return nil
+		}
+		delay := time.Duration((cost - b.available) / b.status.RestoreRate * float64(time.Second))
+		b.mutex.Unlock()
+
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *costLimiter) update(shop string, query string, cost GraphQLCost) {
+	l.mutex.Lock()
+	if len(l.costs) >= maxKnownQueries {
+		l.costs = map[string]float64{}
+	}
+	l.costs[query] = cost.RequestedQueryCost
+	l.mutex.Unlock()
+
+	b := l.bucket(shop)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.status = cost.ThrottleStatus
+	b.available = cost.ThrottleStatus.CurrentlyAvailable
+	b.updatedAt = time.Now()
+	b.known = true
+}
@@ -0,0 +1,165 @@
+package shopify
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBucketWait(t *testing.T) {
+	b := &bucket{limit: RateLimit{Capacity: 2, RestoreRate: 50}, updatedAt: time.Now()}
+	ctx := context.Background()
+
+	// the capacity can be burst
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := b.wait(ctx); err != nil {
+			t.Fatalf("wait() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("wait() within the capacity took %s", elapsed)
+	}
+
+	// then a request waits for one to leak, 20ms at 50 requests per second
+	start = time.Now()
+	if err := b.wait(ctx); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("wait() on a full bucket took %s, want about 20ms", elapsed)
+	}
+}
+
+func TestBucketWaitCancelled(t *testing.T) {
+	b := &bucket{limit: RateLimit{Capacity: 1, RestoreRate: 0.01}, updatedAt: time.Now()}
+	b.fill()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := b.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("wait() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestBucketSync(t *testing.T) {
+	b := &bucket{limit: DefaultRateLimit, level: 10, updatedAt: time.Now()}
+
+	// the requests in flight are not forgotten
+	b.sync(5, 40)
+	if b.level < 9 {
+		t.Errorf("sync() lowered the level to %v", b.level)
+	}
+
+	b.sync(30, 40)
+	if b.level < 30 {
+		t.Errorf("sync() level = %v, want at least 30", b.level)
+	}
+
+	// a Plus shop reports a larger bucket restored twice as fast
+	b.sync(30, 80)
+	if b.limit.Capacity != 80 || b.limit.RestoreRate != 4 {
+		t.Errorf("sync() limit = %+v, want %+v", b.limit, PlusRateLimit)
+	}
+
+	b.sync(30, 0)
+	if b.limit.Capacity != 80 {
+		t.Errorf("sync() with no capacity changed the limit to %+v", b.limit)
+	}
+}
+
+func TestParseCallLimit(t *testing.T) {
+	tests := []struct {
+		header   string
+		used     int
+		capacity int
+		ok       bool
+	}{
+		{"32/40", 32, 40, true},
+		{" 1 / 80 ", 1, 80, true},
+		{"", 0, 0, false},
+		{"32", 0, 0, false},
+		{"a/40", 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		used, capacity, ok := parseCallLimit(tt.header)
+		if used != tt.used || capacity != tt.capacity || ok != tt.ok {
+			t.Errorf("parseCallLimit(%q) = %d, %d, %v, want %d, %d, %v", tt.header, used, capacity, ok, tt.used, tt.capacity, tt.ok)
+		}
+	}
+}
+
+func TestRateLimitValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		limit   RateLimit
+		wantErr bool
+	}{
+		{"default", DefaultRateLimit, false},
+		{"zero capacity", RateLimit{Capacity: 0, RestoreRate: 2}, true},
+		{"negative capacity", RateLimit{Capacity: -1, RestoreRate: 2}, true},
+		{"zero restore rate", RateLimit{Capacity: 40, RestoreRate: 0}, true},
+		{"negative restore rate", RateLimit{Capacity: 40, RestoreRate: -2}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.limit.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	if _, err := NewClient(nil, WithCredentials("key", "secret"), WithRateLimit(RateLimit{Capacity: 40})); err == nil {
+		t.Error("NewClient() succeeded with a zero restore rate")
+	}
+
+	c, err := NewClient(nil, WithCredentials("key", "secret"))
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	if err := c.SetRateLimit("shop.myshopify.com", RateLimit{RestoreRate: 2}); err == nil {
+		t.Error("SetRateLimit() succeeded with a zero capacity")
+	}
+}
+
+func TestCostLimiterWait(t *testing.T) {
+	l := newCostLimiter()
+	ctx := context.Background()
+	const query = "{ shop { name } }"
+
+	// nothing is known before the first response
+	start := time.Now()
+	if err := l.wait(ctx, "shop.myshopify.com", query); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("wait() of an unknown shop took %s", elapsed)
+	}
+
+	// the bucket is empty, the cost of 10 is restored in 100ms
+	l.update("shop.myshopify.com", query, GraphQLCost{
+		RequestedQueryCost: 10,
+		ThrottleStatus:     GraphQLThrottleStatus{MaximumAvailable: 1000, CurrentlyAvailable: 0, RestoreRate: 100},
+	})
+
+	start = time.Now()
+	if err := l.wait(ctx, "shop.myshopify.com", query); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("wait() with an empty bucket took %s, want about 100ms", elapsed)
+	}
+
+	// the buckets are per shop
+	start = time.Now()
+	if err := l.wait(ctx, "other.myshopify.com", query); err != nil {
+		t.Fatalf("wait() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Millisecond {
+		t.Errorf("wait() of another shop took %s", elapsed)
+	}
+}
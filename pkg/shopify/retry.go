@@ -0,0 +1,161 @@
+package shopify
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	accessTokenHeader = "X-Shopify-Access-Token"
+	retryAfterHeader  = "Retry-After"
+)
+
+// RetryPolicy controls how throttled (429) and server error (5xx) responses
+// are retried. Server errors are only retried for idempotent methods, since a
+// POST may have created the resource before failing. Delays grow
+// exponentially from MinBackoff up to MaxBackoff with jitter, unless Shopify
+// asks for a specific delay with Retry-After.
+type RetryPolicy struct {
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.MinBackoff << attempt
+	if delay <= 0 || delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+
+	// equal jitter: wait at least half of the delay
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+func isRetryable(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// shouldRetry reports whether a request sent with method can be sent again
+// after a response with statusCode. A throttled request was not processed,
+// while a server error can happen after the request took effect.
+func shouldRetry(method string, statusCode int) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return true
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return isRetryable(statusCode)
+	default:
+		return false
+	}
+}
+
+func parseRetryAfter(header string) time.Duration {
+	seconds, err := strconv.ParseFloat(header, 64)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// do sends the request through the rate limiter of its shop and retries it
+// according to the retry policy of the client.
+func (c *client) do(request *http.Request) (*http.Response, error) {
+	ctx := request.Context()
+	shop := requestShop(request)
+	logger := c.requestLogger(request)
+	// GraphQL queries are throttled by cost instead of the REST bucket
+	limited := request.Header.Get(accessTokenHeader) != "" &&
+		!strings.HasSuffix(request.URL.Path, "/"+graphqlPath)
+
+	if c.userAgent != "" {
+		request.Header.Set("User-Agent", c.userAgent)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if limited {
+			if err := c.rateLimiter.wait(ctx, shop); err != nil {
+				return nil, err
+			}
+		}
+
+		res, err := c.httpClient.Do(request)
+		if err != nil {
+			return nil, err
+		}
+
+		if limited {
+			c.rateLimiter.update(shop, res)
+		}
+
+		logger := logger.With().Str("request_id", res.Header.Get(RequestIdHeader)).Logger()
+		logger.Debug().
+			Str("method", request.Method).
+			Str("path", request.URL.Path).
+			Int("status_code", res.StatusCode).
+			Int("attempt", attempt+1).
+			Msg("shopify request sent")
+
+		c.checkDeprecation(logger, request, res)
+
+		if !shouldRetry(request.Method, res.StatusCode) || attempt >= c.retryPolicy.MaxRetries {
+			return res, nil
+		}
+
+		if request.Body != nil {
+			if request.GetBody == nil {
+				return res, nil
+			}
+
+			body, err := request.GetBody()
+			if err != nil {
+				return res, nil
+			}
+			request.Body = body
+		}
+
+		delay := parseRetryAfter(res.Header.Get(retryAfterHeader))
+		if delay == 0 {
+			delay = c.retryPolicy.backoff(attempt)
+		}
+
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+
+		logger.Warn().
+			Int("status_code", res.StatusCode).
+			Int("attempt", attempt+1).
+			Dur("delay", delay).
+			Msg("retrying shopify request")
+
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func sleep(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,103 @@
+package shopify
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"2", 2 * time.Second},
+		{"1.5", 1500 * time.Millisecond},
+		{"", 0},
+		{"-1", 0},
+		{"Wed, 21 Oct 2026 07:28:00 GMT", 0},
+	}
+
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.header); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MinBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+
+	for attempt, max := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second, time.Second} {
+		for i := 0; i < 20; i++ {
+			if delay := policy.backoff(attempt); delay < max/2 || delay > max {
+				t.Errorf("backoff(%d) = %s, want between %s and %s", attempt, delay, max/2, max)
+			}
+		}
+	}
+
+	// the shift overflows after many attempts
+	if delay := policy.backoff(100); delay < policy.MaxBackoff/2 || delay > policy.MaxBackoff {
+		t.Errorf("backoff(100) = %s, want at most %s", delay, policy.MaxBackoff)
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	tests := []struct {
+		method     string
+		statusCode int
+		want       bool
+	}{
+		{http.MethodGet, http.StatusTooManyRequests, true},
+		{http.MethodPost, http.StatusTooManyRequests, true},
+		{http.MethodGet, http.StatusBadGateway, true},
+		{http.MethodPut, http.StatusServiceUnavailable, true},
+		{http.MethodDelete, http.StatusInternalServerError, true},
+		{http.MethodPost, http.StatusGatewayTimeout, false},
+		{http.MethodGet, http.StatusNotFound, false},
+	}
+
+	for _, tt := range tests {
+		if got := shouldRetry(tt.method, tt.statusCode); got != tt.want {
+			t.Errorf("shouldRetry(%s, %d) = %v, want %v", tt.method, tt.statusCode, got, tt.want)
+		}
+	}
+}
+
+func TestServerErrorRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	c, err := NewClient(nil,
+		WithCredentials("key", "secret"),
+		WithBaseURLResolver(func(shop string) (*url.URL, error) { return url.Parse(server.URL) }),
+		WithRetryPolicy(RetryPolicy{MaxRetries: 2, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := c.CountProduct(ctx, "shop.myshopify.com", "token", nil); err == nil {
+		t.Fatal("CountProduct() succeeded")
+	}
+	if got := atomic.SwapInt32(&requests, 0); got != 3 {
+		t.Errorf("GET sent %d times, want 3", got)
+	}
+
+	// the product may have been created before the gateway gave up
+	if _, err := c.CreateProduct(ctx, "shop.myshopify.com", "token", Product{Title: "product"}); err == nil {
+		t.Fatal("CreateProduct() succeeded")
+	}
+	if got := atomic.LoadInt32(&requests); got != 1 {
+		t.Errorf("POST sent %d times, want 1", got)
+	}
+}
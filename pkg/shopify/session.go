@@ -0,0 +1,124 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var ErrInvalidSessionToken = errors.New("shopify: invalid session token")
+
+// SessionToken holds the claims of a session token issued by App Bridge to
+// an embedded app.
+type SessionToken struct {
+	Issuer      string `json:"iss"`
+	Destination string `json:"dest"`
+	Audience    string `json:"aud"`
+	Subject     string `json:"sub"`
+	ExpiresAt   int64  `json:"exp"`
+	NotBefore   int64  `json:"nbf"`
+	IssuedAt    int64  `json:"iat"`
+	ID          string `json:"jti"`
+	SessionID   string `json:"sid"`
+
+	// Raw is the encoded token, e.g. to exchange it for an access token.
+	Raw string `json:"-"`
+	// shop is the host of Destination.
+	shop string
+}
+
+// Shop returns the myshopify domain the token was issued for.
+func (t *SessionToken) Shop() string {
+	return t.shop
+}
+
+// UserID returns the ID of the staff member using the app.
+func (t *SessionToken) UserID() string {
+	return t.Subject
+}
+
+type sessionTokenHeader struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+}
+
+// ParseSessionToken verifies the HS256 signature of token with the app
+// secret and validates its claims: the audience must be the app client ID,
+// the token must be valid at now give or take leeway, and the destination
+// must be a shop domain matching the issuer.
+func ParseSessionToken(token string, apiKey string, apiSecret string, leeway time.Duration, now time.Time) (*SessionToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidSessionToken)
+	}
+
+	var header sessionTokenHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+
+	if header.Algorithm != "HS256" {
+		return nil, fmt.Errorf("%w: unexpected algorithm %q", ErrInvalidSessionToken, header.Algorithm)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidSessionToken)
+	}
+
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return nil, fmt.Errorf("%w: signature mismatch", ErrInvalidSessionToken)
+	}
+
+	claims := &SessionToken{Raw: token}
+	if err := decodeSegment(parts[1], claims); err != nil {
+		return nil, err
+	}
+
+	if claims.Audience != apiKey {
+		return nil, fmt.Errorf("%w: unexpected audience", ErrInvalidSessionToken)
+	}
+
+	if now.After(time.Unix(claims.ExpiresAt, 0).Add(leeway)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidSessionToken)
+	}
+
+	if now.Before(time.Unix(claims.NotBefore, 0).Add(-leeway)) {
+		return nil, fmt.Errorf("%w: token not valid yet", ErrInvalidSessionToken)
+	}
+
+	dest, err := url.Parse(claims.Destination)
+	if err != nil || !IsValidShopDomain(dest.Host) {
+		return nil, fmt.Errorf("%w: invalid destination", ErrInvalidSessionToken)
+	}
+
+	iss, err := url.Parse(claims.Issuer)
+	if err != nil || iss.Host != dest.Host {
+		return nil, fmt.Errorf("%w: issuer does not match destination", ErrInvalidSessionToken)
+	}
+
+	claims.shop = dest.Host
+
+	return claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidSessionToken)
+	}
+
+	if err := json.Unmarshal(decoded, v); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidSessionToken)
+	}
+
+	return nil
+}
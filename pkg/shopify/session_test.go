@@ -0,0 +1,93 @@
+package shopify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func signSessionToken(claims SessionToken, apiSecret string) string {
+	encoded, _ := json.Marshal(claims)
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(encoded)
+
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestParseSessionToken(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	valid := SessionToken{
+		Issuer:      "https://shop.myshopify.com/admin",
+		Destination: "https://shop.myshopify.com",
+		Audience:    "api-key",
+		Subject:     "42",
+		ExpiresAt:   now.Add(time.Minute).Unix(),
+		NotBefore:   now.Add(-time.Second).Unix(),
+		IssuedAt:    now.Add(-time.Second).Unix(),
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(claims *SessionToken)
+		secret  string
+		wantErr bool
+	}{
+		{name: "valid", modify: func(claims *SessionToken) {}},
+		{name: "expired within leeway", modify: func(claims *SessionToken) { claims.ExpiresAt = now.Add(-5 * time.Second).Unix() }},
+		{name: "expired", modify: func(claims *SessionToken) { claims.ExpiresAt = now.Add(-time.Minute).Unix() }, wantErr: true},
+		{name: "not valid yet", modify: func(claims *SessionToken) { claims.NotBefore = now.Add(time.Minute).Unix() }, wantErr: true},
+		{name: "wrong audience", modify: func(claims *SessionToken) { claims.Audience = "other-api-key" }, wantErr: true},
+		{
+			name:    "issuer does not match destination",
+			modify:  func(claims *SessionToken) { claims.Issuer = "https://other.myshopify.com/admin" },
+			wantErr: true,
+		},
+		{
+			name:    "destination is not a shop",
+			modify:  func(claims *SessionToken) { claims.Destination = "https://example.com" },
+			wantErr: true,
+		},
+		{name: "wrong secret", modify: func(claims *SessionToken) {}, secret: "other-secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid
+			tt.modify(&claims)
+			secret := tt.secret
+			if secret == "" {
+				secret = "api-secret"
+			}
+
+			session, err := ParseSessionToken(signSessionToken(claims, secret), "api-key", "api-secret", 10*time.Second, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSessionToken) {
+					t.Errorf("ParseSessionToken() error = %v, want ErrInvalidSessionToken", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseSessionToken() error = %v", err)
+			}
+			if session.Shop() != "shop.myshopify.com" || session.UserID() != "42" {
+				t.Errorf("ParseSessionToken() shop = %q, user = %q", session.Shop(), session.UserID())
+			}
+		})
+	}
+}
+
+func TestParseSessionTokenMalformed(t *testing.T) {
+	for _, token := range []string{"", "a.b", "a.b.c", "e30.e30.e30"} {
+		if _, err := ParseSessionToken(token, "api-key", "api-secret", 0, time.Now()); !errors.Is(err, ErrInvalidSessionToken) {
+			t.Errorf("ParseSessionToken(%q) error = %v, want ErrInvalidSessionToken", token, err)
+		}
+	}
+}
@@ -2,50 +2,98 @@ package shopify
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"net/url"
 
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/zeals-co-ltd/shopify-app-example/internal/config"
 )
 
 const (
-	apiVersion = "2023-07"
+	DefaultAPIVersion = "2026-07"
 )
 
 type Client interface {
 	WebhookService
 	OauthService
+	ProductService
+	GraphQLService
+	BulkOperationService
+
+	// UseAPIVersion returns a client sending its requests with the given
+	// Admin API version. It shares the rate limits of the original client.
+	UseAPIVersion(version string) Client
+
+	// SetRateLimit overrides the REST API bucket of shop, e.g. with
+	// PlusRateLimit for Shopify Plus stores. Both the capacity and the
+	// restore rate must be positive.
+	SetRateLimit(shop string, limit RateLimit) error
 }
 
 type client struct {
-	httpClient *http.Client
-	apiKey     string
-	apiSecret  string
+	httpClient      *http.Client
+	apiKey          string
+	apiSecret       string
+	baseURLResolver BaseURLResolver
+	apiVersion      string
+	userAgent       string
+	logger          zerolog.Logger
+	rateLimiter     *rateLimiter
+	costLimiter     *costLimiter
+	retryPolicy     RetryPolicy
+	operations      *operationRegistry
+	deprecations    *deprecationReporter
 }
 
-func NewClient(httpClient *http.Client) (Client, error) {
-	apiSecret, err := config.MustGet("SHOPIFY_CLIENT_SECRET")
-	if err != nil {
-		return nil, err
+// NewClient creates a client sending its requests with httpClient, or
+// http.DefaultClient when nil. The app credentials must be given with
+// WithCredentials.
+func NewClient(httpClient *http.Client, opts ...ClientOption) (Client, error) {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
 	}
 
-	apiKey, err := config.MustGet("SHOPIFY_CLIENT_ID")
-	if err != nil {
+	c := &client{
+		httpClient:      httpClient,
+		baseURLResolver: defaultBaseURLResolver,
+		apiVersion:      DefaultAPIVersion,
+		logger:          log.Logger,
+		rateLimiter:     newRateLimiter(DefaultRateLimit),
+		costLimiter:     newCostLimiter(),
+		retryPolicy:     DefaultRetryPolicy,
+		operations:      &operationRegistry{operations: map[string]string{}},
+		deprecations:    &deprecationReporter{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.apiKey == "" || c.apiSecret == "" {
+		return nil, errors.New("app credentials are required")
+	}
+
+	if c.baseURLResolver == nil {
+		return nil, errors.New("base url resolver is required")
+	}
+
+	if c.apiVersion == "" {
+		return nil, errors.New("api version is required")
+	}
+
+	if err := c.rateLimiter.limit.validate(); err != nil {
 		return nil, err
 	}
 
-	return &client{
-		httpClient: httpClient,
-		apiKey:     apiKey,
-		apiSecret:  apiSecret,
-	}, nil
+	return c, nil
 }
 
 func NewRequest(
+	ctx context.Context,
 	method string,
 	url *url.URL,
 	accessToken string,
@@ -61,7 +109,7 @@ func NewRequest(
 		payload = bytes.NewBuffer(buf)
 	}
 
-	req, err := http.NewRequest(method, url.String(), payload)
+	req, err := http.NewRequestWithContext(ctx, method, url.String(), payload)
 	if err != nil {
 		log.Error().Err(err).Msg("error")
 		return nil, err
@@ -71,30 +119,94 @@ func NewRequest(
 	req.Header.Add("Accept", "application/json")
 
 	if accessToken != "" {
-		req.Header.Add("X-Shopify-Access-Token", accessToken)
+		req.Header.Add(accessTokenHeader, accessToken)
 	}
 
 	return req, nil
 }
 
+// newRequest creates a request carrying shop in its context, so that it is
+// rate limited and logged by shop even when the base url is shared.
+func (c *client) newRequest(
+	ctx context.Context,
+	shop string,
+	method string,
+	url *url.URL,
+	accessToken string,
+	body interface{},
+) (*http.Request, error) {
+	return NewRequest(contextWithShop(ctx, shop), method, url, accessToken, body)
+}
+
+func (c *client) SetRateLimit(shop string, limit RateLimit) error {
+	if err := limit.validate(); err != nil {
+		return err
+	}
+
+	c.rateLimiter.setLimit(shop, limit)
+
+	return nil
+}
+
+// createUrl builds the Admin REST API url of the given resource path for shop.
+func (c *client) createUrl(shop string, path string) (*url.URL, error) {
+	baseUrl, err := c.baseURLResolver(shop)
+	if err != nil {
+		return nil, err
+	}
+
+	return baseUrl.JoinPath("admin/api", c.apiVersion, path), nil
+}
+
+func (c *client) count(ctx context.Context, shop string, requestUrl *url.URL, accessToken string, options interface{}) (int, error) {
+	if err := setOptions(requestUrl, options); err != nil {
+		return 0, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	result := new(countResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.Count, nil
+}
+
 func (c *client) SendRequest(request *http.Request, response interface{}) error {
-	res, err := c.httpClient.Do(request)
+	_, err := c.SendRequestWithPagination(request, response)
+	return err
+}
+
+// SendRequestWithPagination sends the request like SendRequest and also
+// returns the pagination advertised by the Link header of the response.
+func (c *client) SendRequestWithPagination(request *http.Request, response interface{}) (*Pagination, error) {
+	res, err := c.do(request)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer res.Body.Close()
 
 	if !(res.StatusCode >= 200 && res.StatusCode <= 299) {
-		return fmt.Errorf("error with status code %d", res.StatusCode)
+		return nil, newAPIError(res)
+	}
+
+	pagination, err := parsePagination(res.Header.Get("Link"))
+	if err != nil {
+		return nil, err
 	}
 
 	if response != nil {
 		decoder := json.NewDecoder(res.Body)
 		err = decoder.Decode(response)
 		if err != nil {
-			return err
+			return nil, err
 		}
 	}
 
-	return nil
+	return pagination, nil
 }
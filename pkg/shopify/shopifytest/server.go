@@ -0,0 +1,850 @@
+// Package shopifytest provides a fake Shopify Admin API server to test the
+// shopify package and the OAuth flow without a real store.
+//
+//	server := shopifytest.NewServer("api-key", "api-secret")
+//	defer server.Close()
+//
+//	client, _ := shopify.NewClient(
+//		server.Client(),
+//		shopify.WithCredentials(server.APIKey, server.APISecret),
+//		shopify.WithBaseURLResolver(server.BaseURLResolver()),
+//	)
+package shopifytest
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zeals-co-ltd/shopify-app-example/pkg/shopify"
+)
+
+const (
+	DefaultShop = "example.myshopify.com"
+
+	defaultPageSize = 50
+	maxPageSize     = 250
+)
+
+// GraphQLHandler answers a GraphQL request with the content of "data", or
+// with top level errors.
+type GraphQLHandler func(request shopify.GraphQLRequest) (interface{}, shopify.GraphQLErrors)
+
+type graphqlStub struct {
+	match   string
+	handler GraphQLHandler
+}
+
+// Server is an in-memory Shopify store served over HTTP. It is safe for
+// concurrent use.
+type Server struct {
+	*httptest.Server
+
+	APIKey    string
+	APISecret string
+	// Shop is the myshopify domain reported in the webhooks and the OAuth
+	// responses.
+	Shop string
+	// Scope is granted to every access token.
+	Scope string
+	// RateLimit is the REST bucket advertised in the call limit header.
+	RateLimit shopify.RateLimit
+	// GraphQLCost is the cost reported for every GraphQL query.
+	GraphQLCost float64
+	// OnlineTokenExpiresIn is the expires_in of the online access tokens, 0
+	// leaves it out.
+	OnlineTokenExpiresIn int
+
+	mutex        sync.Mutex
+	accessTokens map[string]bool
+	webhooks     map[int64]shopify.Webhook
+	products     map[int64]shopify.Product
+	nextID       int64
+	level        float64
+	leakedAt     time.Time
+	throttled    int
+	graphqlStubs []graphqlStub
+	graphqlLevel float64
+	requests     []*http.Request
+}
+
+// NewServer starts a fake store accepting the given app credentials.
+func NewServer(apiKey string, apiSecret string) *Server {
+	s := &Server{
+		APIKey:               apiKey,
+		APISecret:            apiSecret,
+		Shop:                 DefaultShop,
+		Scope:                "read_products,write_products",
+		RateLimit:            shopify.DefaultRateLimit,
+		GraphQLCost:          1,
+		OnlineTokenExpiresIn: 86399,
+		accessTokens:         map[string]bool{},
+		webhooks:             map[int64]shopify.Webhook{},
+		products:             map[int64]shopify.Product{},
+		nextID:               1000,
+		leakedAt:             time.Now(),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// BaseURLResolver points every shop to the server.
+func (s *Server) BaseURLResolver() shopify.BaseURLResolver {
+	return func(shop string) (*url.URL, error) {
+		return url.Parse(s.URL)
+	}
+}
+
+// IssueAccessToken returns a valid access token without going through OAuth.
+func (s *Server) IssueAccessToken() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.issueAccessToken()
+}
+
+// RevokeAccessToken makes token rejected with 401, as after an uninstall.
+func (s *Server) RevokeAccessToken(token string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.accessTokens, token)
+}
+
+// ThrottleNext answers the next n Admin API requests with 429.
+func (s *Server) ThrottleNext(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.throttled = n
+}
+
+// StubGraphQL answers the GraphQL requests whose query contains match with
+// handler. Stubs are matched in the order they were added.
+func (s *Server) StubGraphQL(match string, handler GraphQLHandler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.graphqlStubs = append(s.graphqlStubs, graphqlStub{match: match, handler: handler})
+}
+
+// Requests returns the requests received so far, bodies excluded.
+func (s *Server) Requests() []*http.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// Webhooks returns the registered webhooks ordered by id.
+func (s *Server) Webhooks() []shopify.Webhook {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return sortedValues(s.webhooks, func(w shopify.Webhook) int64 { return w.ID })
+}
+
+// Products returns the products of the store ordered by id.
+func (s *Server) Products() []shopify.Product {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return sortedValues(s.products, func(p shopify.Product) int64 { return p.ID })
+}
+
+// AddProduct stores product as is, a new id is assigned when it has none.
+func (s *Server) AddProduct(product shopify.Product) shopify.Product {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.saveProduct(product)
+}
+
+// SessionToken returns a session token of the shop as App Bridge would
+// issue it for the given user, valid for a minute.
+func (s *Server) SessionToken(userId int64) string {
+	now := time.Now()
+	claims, _ := json.Marshal(shopify.SessionToken{
+		Issuer:      "https://" + s.Shop + "/admin",
+		Destination: "https://" + s.Shop,
+		Audience:    s.APIKey,
+		Subject:     strconv.FormatInt(userId, 10),
+		ExpiresAt:   now.Add(time.Minute).Unix(),
+		NotBefore:   now.Unix(),
+		IssuedAt:    now.Unix(),
+		ID:          randomHex(16),
+		SessionID:   randomHex(16),
+	})
+
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, []byte(s.APISecret))
+	mac.Write([]byte(payload))
+
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SignWebhook returns the X-Shopify-Hmac-Sha256 header of body.
+func (s *Server) SignWebhook(body []byte) string {
+	mac := hmac.New(sha256.New, []byte(s.APISecret))
+	mac.Write(body)
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SendWebhook delivers payload to address as Shopify would.
+func (s *Server) SendWebhook(address string, topic string, payload interface{}) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("POST", address, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(shopify.WebhookHmacHeader, s.SignWebhook(body))
+	req.Header.Set(shopify.WebhookTopicHeader, topic)
+	req.Header.Set(shopify.WebhookShopDomainHeader, s.Shop)
+	req.Header.Set(shopify.WebhookIdHeader, randomHex(16))
+
+	return http.DefaultClient.Do(req)
+}
+
+// SignQuery adds the hmac parameter Shopify appends to the OAuth callback
+// and the app urls.
+func (s *Server) SignQuery(query url.Values) url.Values {
+	signed := url.Values{}
+	for key, values := range query {
+		signed[key] = values
+	}
+	signed.Del("hmac")
+
+	message, _ := url.QueryUnescape(signed.Encode())
+	mac := hmac.New(sha256.New, []byte(s.APISecret))
+	mac.Write([]byte(message))
+	signed.Set("hmac", hex.EncodeToString(mac.Sum(nil)))
+
+	return signed
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, r)
+	w.Header().Set(shopify.RequestIdHeader, randomHex(8))
+
+	if r.URL.Path == "/admin/oauth/access_token" {
+		s.handleAccessToken(w, r)
+		return
+	}
+
+	if r.URL.Path == "/admin/oauth/access_scopes.json" {
+		s.handleAccessScopes(w, r)
+		return
+	}
+
+	version, path, ok := parseAdminPath(r.URL.Path)
+	if !ok {
+		writeErrors(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	w.Header().Set("X-Shopify-API-Version", version)
+
+	if !s.accessTokens[r.Header.Get("X-Shopify-Access-Token")] {
+		writeErrors(w, http.StatusUnauthorized, "[API] Invalid API key or access token (unrecognized login or wrong password)")
+		return
+	}
+
+	if path == "graphql.json" {
+		s.handleGraphQL(w, r)
+		return
+	}
+
+	if !s.takeCall(w) {
+		return
+	}
+
+	segments := strings.Split(strings.TrimSuffix(path, ".json"), "/")
+	switch segments[0] {
+	case "webhooks":
+		s.handleWebhooks(w, r, segments[1:])
+	case "products":
+		s.handleProducts(w, r, segments[1:])
+	default:
+		writeErrors(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// takeCall fills the REST bucket and writes the call limit header, or
+// answers 429 when the bucket is full.
+func (s *Server) takeCall(w http.ResponseWriter) bool {
+	now := time.Now()
+	s.level = math.Max(0, s.level-now.Sub(s.leakedAt).Seconds()*s.RateLimit.RestoreRate)
+	s.leakedAt = now
+
+	if s.throttled > 0 || s.level+1 > float64(s.RateLimit.Capacity) {
+		if s.throttled > 0 {
+			s.throttled--
+		}
+		w.Header().Set("Retry-After", "1.0")
+		writeErrors(w, http.StatusTooManyRequests, "Exceeded 2 calls per second for api client. Reduce request rates to resume uninterrupted service.")
+		return false
+	}
+
+	s.level++
+	w.Header().Set("X-Shopify-Shop-Api-Call-Limit", fmt.Sprintf("%d/%d", int(math.Ceil(s.level)), s.RateLimit.Capacity))
+
+	return true
+}
+
+func (s *Server) handleAccessToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrors(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	var request shopify.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	if request.ClientId != s.APIKey || request.ClientSecret != s.APISecret {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_client",
+			"error_description": "Client credentials are invalid",
+		})
+		return
+	}
+
+	if request.GrantType == shopify.TokenExchangeGrantType {
+		s.exchangeToken(w, request)
+		return
+	}
+
+	if request.Code == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_request",
+			"error_description": "The authorization code was not found or was already used",
+		})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, shopify.TokenResponse{
+		AccessToken: s.issueAccessToken(),
+		Scope:       s.Scope,
+	})
+}
+
+func (s *Server) handleAccessScopes(w http.ResponseWriter, r *http.Request) {
+	if !s.accessTokens[r.Header.Get("X-Shopify-Access-Token")] {
+		writeErrors(w, http.StatusUnauthorized, "[API] Invalid API key or access token (unrecognized login or wrong password)")
+		return
+	}
+
+	var resource shopify.AccessScopesResource
+	for _, scope := range strings.Split(s.Scope, ",") {
+		resource.AccessScopes = append(resource.AccessScopes, shopify.AccessScope{Handle: scope})
+	}
+
+	writeJSON(w, http.StatusOK, resource)
+}
+
+func (s *Server) exchangeToken(w http.ResponseWriter, request shopify.TokenRequest) {
+	session, err := shopify.ParseSessionToken(request.SubjectToken, s.APIKey, s.APISecret, time.Minute, time.Now())
+	if err != nil || session.Shop() != s.Shop || request.SubjectTokenType != shopify.IdTokenType {
+		writeJSON(w, http.StatusBadRequest, map[string]string{
+			"error":             "invalid_subject_token",
+			"error_description": "The subject token is invalid",
+		})
+		return
+	}
+
+	response := shopify.TokenResponse{
+		AccessToken: s.issueAccessToken(),
+		Scope:       s.Scope,
+	}
+
+	if request.RequestedTokenType == shopify.OnlineAccessToken {
+		userId, _ := strconv.ParseInt(session.UserID(), 10, 64)
+		response.ExpiresIn = s.OnlineTokenExpiresIn
+		response.AssociatedUserScope = s.Scope
+		response.AssociatedUser = &shopify.AssociatedUser{
+			ID:            userId,
+			FirstName:     "John",
+			LastName:      "Smith",
+			Email:         "john@example.com",
+			EmailVerified: true,
+			Locale:        "en",
+		}
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (s *Server) handleWebhooks(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			query, offset, ok := pageQuery(w, r)
+			if !ok {
+				return
+			}
+			webhooks := s.findWebhooks(query)
+			page, ok := s.paginate(w, r, query, offset, len(webhooks))
+			if !ok {
+				return
+			}
+			writeJSON(w, http.StatusOK, shopify.WebhookResources{Webhooks: webhooks[page[0]:page[1]]})
+		case http.MethodPost:
+			var request shopify.WebhookResource
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Webhook == nil {
+				writeErrors(w, http.StatusBadRequest, "Required parameter missing or invalid")
+				return
+			}
+			webhook := *request.Webhook
+			for _, existing := range s.webhooks {
+				if existing.Topic == webhook.Topic && existing.Address == webhook.Address {
+					writeErrors(w, http.StatusUnprocessableEntity, map[string][]string{
+						"address": {"for this topic has already been taken"},
+					})
+					return
+				}
+			}
+			now := time.Now()
+			webhook.ID = s.newID()
+			webhook.CreatedAt = &now
+			webhook.UpdatedAt = &now
+			s.webhooks[webhook.ID] = webhook
+			writeJSON(w, http.StatusCreated, shopify.WebhookResource{Webhook: &webhook})
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+
+	if segments[0] == "count" {
+		writeJSON(w, http.StatusOK, map[string]int{"count": len(s.findWebhooks(r.URL.Query()))})
+		return
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	webhook, ok := s.webhooks[id]
+	if err != nil || !ok {
+		writeErrors(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, shopify.WebhookResource{Webhook: &webhook})
+	case http.MethodPut:
+		var request shopify.WebhookResource
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Webhook == nil {
+			writeErrors(w, http.StatusBadRequest, "Required parameter missing or invalid")
+			return
+		}
+		if request.Webhook.Address != "" {
+			webhook.Address = request.Webhook.Address
+		}
+		if request.Webhook.Format != "" {
+			webhook.Format = request.Webhook.Format
+		}
+		now := time.Now()
+		webhook.UpdatedAt = &now
+		s.webhooks[id] = webhook
+		writeJSON(w, http.StatusOK, shopify.WebhookResource{Webhook: &webhook})
+	case http.MethodDelete:
+		delete(s.webhooks, id)
+		writeJSON(w, http.StatusOK, map[string]string{})
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *Server) handleProducts(w http.ResponseWriter, r *http.Request, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			query, offset, ok := pageQuery(w, r)
+			if !ok {
+				return
+			}
+			products := s.findProducts(query)
+			page, ok := s.paginate(w, r, query, offset, len(products))
+			if !ok {
+				return
+			}
+			writeJSON(w, http.StatusOK, shopify.ProductResources{Products: products[page[0]:page[1]]})
+		case http.MethodPost:
+			var request shopify.ProductResource
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Product == nil {
+				writeErrors(w, http.StatusBadRequest, "Required parameter missing or invalid")
+				return
+			}
+			if request.Product.Title == "" {
+				writeErrors(w, http.StatusUnprocessableEntity, map[string][]string{"title": {"can't be blank"}})
+				return
+			}
+			request.Product.ID = 0
+			product := s.saveProduct(*request.Product)
+			writeJSON(w, http.StatusCreated, shopify.ProductResource{Product: &product})
+		default:
+			writeErrors(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+
+	if segments[0] == "count" {
+		writeJSON(w, http.StatusOK, map[string]int{"count": len(s.findProducts(r.URL.Query()))})
+		return
+	}
+
+	id, err := strconv.ParseInt(segments[0], 10, 64)
+	product, ok := s.products[id]
+	if err != nil || !ok {
+		writeErrors(w, http.StatusNotFound, "Not Found")
+		return
+	}
+
+	if len(segments) > 1 && segments[1] == "variants" && r.Method == http.MethodGet {
+		query, offset, ok := pageQuery(w, r)
+		if !ok {
+			return
+		}
+		page, ok := s.paginate(w, r, query, offset, len(product.Variants))
+		if !ok {
+			return
+		}
+		writeJSON(w, http.StatusOK, shopify.VariantResources{Variants: product.Variants[page[0]:page[1]]})
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, shopify.ProductResource{Product: &product})
+	case http.MethodPut:
+		var request shopify.ProductResource
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Product == nil {
+			writeErrors(w, http.StatusBadRequest, "Required parameter missing or invalid")
+			return
+		}
+		update := *request.Product
+		update.ID = id
+		update.CreatedAt = product.CreatedAt
+		if update.Title == "" {
+			update.Title = product.Title
+		}
+		if update.Variants == nil {
+			update.Variants = product.Variants
+		}
+		product = s.saveProduct(update)
+		writeJSON(w, http.StatusOK, shopify.ProductResource{Product: &product})
+	case http.MethodDelete:
+		delete(s.products, id)
+		writeJSON(w, http.StatusOK, map[string]string{})
+	default:
+		writeErrors(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (s *Server) handleGraphQL(w http.ResponseWriter, r *http.Request) {
+	var request shopify.GraphQLRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeErrors(w, http.StatusBadRequest, "Invalid JSON")
+		return
+	}
+
+	maximum := float64(s.RateLimit.Capacity) * 25
+	restoreRate := s.RateLimit.RestoreRate * 25
+	cost := shopify.GraphQLCost{
+		RequestedQueryCost: s.GraphQLCost,
+		ThrottleStatus: shopify.GraphQLThrottleStatus{
+			MaximumAvailable:   maximum,
+			CurrentlyAvailable: maximum,
+			RestoreRate:        restoreRate,
+		},
+	}
+
+	if s.throttled > 0 {
+		s.throttled--
+		cost.ThrottleStatus.CurrentlyAvailable = 0
+		writeJSON(w, http.StatusOK, shopify.GraphQLResponse{
+			Errors: shopify.GraphQLErrors{{
+				Message:    "Throttled",
+				Extensions: map[string]interface{}{"code": "THROTTLED"},
+			}},
+			Extensions: &shopify.GraphQLExtensions{Cost: &cost},
+		})
+		return
+	}
+
+	actual := s.GraphQLCost
+	cost.ActualQueryCost = &actual
+	cost.ThrottleStatus.CurrentlyAvailable = maximum - actual
+
+	response := shopify.GraphQLResponse{
+		Extensions: &shopify.GraphQLExtensions{Cost: &cost},
+	}
+
+	var handler GraphQLHandler
+	for _, stub := range s.graphqlStubs {
+		if strings.Contains(request.Query, stub.match) {
+			handler = stub.handler
+			break
+		}
+	}
+
+	if handler == nil {
+		response.Errors = shopify.GraphQLErrors{{Message: "no stub matches the query"}}
+		writeJSON(w, http.StatusOK, response)
+		return
+	}
+
+	data, errs := handler(request)
+	response.Errors = errs
+	if data != nil {
+		encoded, err := json.Marshal(data)
+		if err != nil {
+			writeErrors(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		response.Data = encoded
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// pageQuery returns the filters of a list request and the offset of the
+// requested page. As with Shopify, page_info carries the filters of the first
+// page and cannot be combined with other filters than limit and fields.
+func pageQuery(w http.ResponseWriter, r *http.Request) (url.Values, int, bool) {
+	query := r.URL.Query()
+
+	pageInfo := query.Get("page_info")
+	if pageInfo == "" {
+		return query, 0, true
+	}
+
+	for key := range query {
+		if key != "page_info" && key != "limit" && key != "fields" {
+			writeErrors(w, http.StatusBadRequest, map[string][]string{key: {"cannot be passed with page_info"}})
+			return nil, 0, false
+		}
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(pageInfo)
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "page_info is invalid")
+		return nil, 0, false
+	}
+
+	filters, err := url.ParseQuery(string(decoded))
+	if err != nil {
+		writeErrors(w, http.StatusBadRequest, "page_info is invalid")
+		return nil, 0, false
+	}
+
+	offset, err := strconv.Atoi(filters.Get("offset"))
+	if err != nil || offset < 0 {
+		writeErrors(w, http.StatusBadRequest, "page_info is invalid")
+		return nil, 0, false
+	}
+	filters.Del("offset")
+
+	return filters, offset, true
+}
+
+// paginate returns the bounds of the page starting at offset and writes the
+// Link header. page_info holds the offset of the page along with the filters
+// of the request.
+func (s *Server) paginate(w http.ResponseWriter, r *http.Request, filters url.Values, offset int, total int) ([2]int, bool) {
+	query := r.URL.Query()
+
+	limit := defaultPageSize
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			writeErrors(w, http.StatusBadRequest, "limit is invalid")
+			return [2]int{}, false
+		}
+		limit = parsed
+	}
+
+	start := int(math.Min(float64(offset), float64(total)))
+	end := int(math.Min(float64(start+limit), float64(total)))
+
+	link := func(offset int, rel string) string {
+		linkQuery := url.Values{}
+		linkQuery.Set("limit", strconv.Itoa(limit))
+		pageInfo := url.Values{}
+		for key, values := range filters {
+			if key != "limit" && key != "fields" {
+				pageInfo[key] = values
+			}
+		}
+		pageInfo.Set("offset", strconv.Itoa(offset))
+		linkQuery.Set("page_info", base64.RawURLEncoding.EncodeToString([]byte(pageInfo.Encode())))
+		if fields := query.Get("fields"); fields != "" {
+			linkQuery.Set("fields", fields)
+		}
+		return fmt.Sprintf(`<%s%s?%s>; rel="%s"`, s.URL, r.URL.Path, linkQuery.Encode(), rel)
+	}
+
+	var links []string
+	if start > 0 {
+		links = append(links, link(int(math.Max(0, float64(start-limit))), "previous"))
+	}
+	if end < total {
+		links = append(links, link(end, "next"))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+
+	return [2]int{start, end}, true
+}
+
+// findProducts returns the products matching the filters of query, sorted
+// by id.
+func (s *Server) findProducts(query url.Values) []shopify.Product {
+	sinceId, _ := strconv.ParseInt(query.Get("since_id"), 10, 64)
+
+	var ids map[string]bool
+	if value := query.Get("ids"); value != "" {
+		ids = map[string]bool{}
+		for _, id := range strings.Split(value, ",") {
+			ids[strings.TrimSpace(id)] = true
+		}
+	}
+
+	products := []shopify.Product{}
+	for _, product := range sortedValues(s.products, func(p shopify.Product) int64 { return p.ID }) {
+		if product.ID <= sinceId || (ids != nil && !ids[strconv.FormatInt(product.ID, 10)]) {
+			continue
+		}
+
+		if matchQuery(query, "title", product.Title) &&
+			matchQuery(query, "vendor", product.Vendor) &&
+			matchQuery(query, "handle", product.Handle) &&
+			matchQuery(query, "product_type", product.ProductType) &&
+			matchQuery(query, "status", product.Status) {
+			products = append(products, product)
+		}
+	}
+
+	return products
+}
+
+// findWebhooks returns the webhooks matching the filters of query, sorted by
+// id.
+func (s *Server) findWebhooks(query url.Values) []shopify.Webhook {
+	webhooks := []shopify.Webhook{}
+	for _, webhook := range sortedValues(s.webhooks, func(w shopify.Webhook) int64 { return w.ID }) {
+		if matchQuery(query, "topic", webhook.Topic) && matchQuery(query, "address", webhook.Address) {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	return webhooks
+}
+
+func (s *Server) saveProduct(product shopify.Product) shopify.Product {
+	now := time.Now()
+	if product.ID == 0 {
+		product.ID = s.newID()
+	}
+	if product.CreatedAt == nil {
+		product.CreatedAt = &now
+	}
+	product.UpdatedAt = &now
+	product.AdminGraphqlApiId = fmt.Sprintf("gid://shopify/Product/%d", product.ID)
+
+	for i := range product.Variants {
+		if product.Variants[i].ID == 0 {
+			product.Variants[i].ID = s.newID()
+		}
+		product.Variants[i].ProductID = product.ID
+	}
+
+	s.products[product.ID] = product
+
+	return product
+}
+
+func (s *Server) issueAccessToken() string {
+	token := "shpat_" + randomHex(16)
+	s.accessTokens[token] = true
+
+	return token
+}
+
+func (s *Server) newID() int64 {
+	s.nextID++
+	return s.nextID
+}
+
+// parseAdminPath splits "/admin/api/{version}/{path}".
+func parseAdminPath(path string) (string, string, bool) {
+	rest, found := strings.CutPrefix(path, "/admin/api/")
+	if !found {
+		return "", "", false
+	}
+
+	version, resource, found := strings.Cut(rest, "/")
+	if !found || resource == "" {
+		return "", "", false
+	}
+
+	return version, resource, true
+}
+
+func matchQuery(query url.Values, key string, value string) bool {
+	expected := query.Get(key)
+	return expected == "" || expected == value
+}
+
+func sortedValues[T any](values map[int64]T, id func(T) int64) []T {
+	results := make([]T, 0, len(values))
+	for _, value := range values {
+		results = append(results, value)
+	}
+	sort.Slice(results, func(i, j int) bool { return id(results[i]) < id(results[j]) })
+
+	return results
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeErrors(w http.ResponseWriter, statusCode int, errors interface{}) {
+	writeJSON(w, statusCode, map[string]interface{}{"errors": errors})
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
@@ -3,8 +3,13 @@ package shopify
 import (
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"fmt"
 	"net/url"
+	"regexp"
+	"strings"
 )
 
 func VerifyAuthUrl(u *url.URL, apiSecret string) (bool, error) {
@@ -31,3 +36,47 @@ func VerifyAuthUrl(u *url.URL, apiSecret string) (bool, error) {
 
 	return hmac.Equal(expectedMac, actualMac), nil
 }
+
+// VerifyWebhook checks the X-Shopify-Hmac-Sha256 header of a webhook against
+// the raw request body.
+func VerifyWebhook(body []byte, messageMAC string, apiSecret string) (bool, error) {
+	mac := hmac.New(sha256.New, []byte(apiSecret))
+	mac.Write(body)
+	expectedMac := mac.Sum(nil)
+
+	actualMac, err := base64.StdEncoding.DecodeString(messageMAC)
+	if err != nil {
+		return false, err
+	}
+
+	return hmac.Equal(expectedMac, actualMac), nil
+}
+
+var shopDomainRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9\-]*\.myshopify\.com$`)
+
+// IsValidShopDomain reports whether shop is a myshopify.com domain, which
+// must be checked before sending any request to it.
+func IsValidShopDomain(shop string) bool {
+	return shopDomainRegex.MatchString(shop)
+}
+
+var hostRegex = regexp.MustCompile(`^(admin\.shopify\.com/store/[a-zA-Z0-9][a-zA-Z0-9\-]*|[a-zA-Z0-9][a-zA-Z0-9\-]*\.myshopify\.com/admin)$`)
+
+// DecodeHost decodes the host parameter given by Shopify to embedded apps,
+// the base64 encoded admin url of the shop, e.g.
+// "admin.shopify.com/store/example".
+func DecodeHost(host string) (string, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(host, "="))
+	if err != nil {
+		decoded, err = base64.RawStdEncoding.DecodeString(strings.TrimRight(host, "="))
+	}
+	if err != nil {
+		return "", errors.New("host is not base64 encoded")
+	}
+
+	if !hostRegex.Match(decoded) {
+		return "", fmt.Errorf("host %q is not a Shopify admin", decoded)
+	}
+
+	return string(decoded), nil
+}
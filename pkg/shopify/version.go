@@ -0,0 +1,93 @@
+package shopify
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	apiVersionHeader       = "X-Shopify-API-Version"
+	deprecatedReasonHeader = "X-Shopify-API-Deprecated-Reason"
+)
+
+var (
+	adminPathRegex = regexp.MustCompile(`/admin/api/([^/]+)/`)
+	idSegmentRegex = regexp.MustCompile(`/\d+(\.json|/|$)`)
+)
+
+// Deprecation describes a response of Shopify that was served with another
+// API version than requested, or that flagged the request as deprecated.
+type Deprecation struct {
+	Shop   string
+	Method string
+	// Endpoint is the path of the request with the resource ids replaced by
+	// ":id", e.g. "/admin/api/2026-07/products/:id.json".
+	Endpoint         string
+	RequestedVersion string
+	ServedVersion    string
+	// Reason is the X-Shopify-API-Deprecated-Reason header, empty when the
+	// request is not deprecated.
+	Reason string
+}
+
+// VersionMismatch reports whether Shopify served another version than the
+// requested one, typically because the requested version is unsupported.
+func (d Deprecation) VersionMismatch() bool {
+	return d.ServedVersion != "" && d.ServedVersion != d.RequestedVersion
+}
+
+// DeprecationHandler is called for every deprecated response, e.g. to count
+// them in a metric.
+type DeprecationHandler func(deprecation Deprecation)
+
+// deprecationReporter logs each deprecated endpoint once and passes every
+// deprecated response to the handler of the client.
+type deprecationReporter struct {
+	handler DeprecationHandler
+	logged  sync.Map
+}
+
+func (c *client) UseAPIVersion(version string) Client {
+	clone := *c
+	clone.apiVersion = version
+
+	return &clone
+}
+
+func (c *client) checkDeprecation(logger zerolog.Logger, request *http.Request, res *http.Response) {
+	matches := adminPathRegex.FindStringSubmatch(request.URL.Path)
+	if matches == nil {
+		return
+	}
+
+	deprecation := Deprecation{
+		Shop:             requestShop(request),
+		Method:           request.Method,
+		Endpoint:         idSegmentRegex.ReplaceAllString(request.URL.Path, "/:id$1"),
+		RequestedVersion: matches[1],
+		ServedVersion:    res.Header.Get(apiVersionHeader),
+		Reason:           res.Header.Get(deprecatedReasonHeader),
+	}
+	if !deprecation.VersionMismatch() && deprecation.Reason == "" {
+		return
+	}
+
+	key := strings.Join([]string{deprecation.Method, deprecation.Endpoint, deprecation.ServedVersion, deprecation.Reason}, " ")
+	if _, logged := c.deprecations.logged.LoadOrStore(key, true); !logged {
+		logger.Warn().
+			Str("method", deprecation.Method).
+			Str("endpoint", deprecation.Endpoint).
+			Str("requested_version", deprecation.RequestedVersion).
+			Str("served_version", deprecation.ServedVersion).
+			Str("reason", deprecation.Reason).
+			Msg("deprecated shopify api usage")
+	}
+
+	if c.deprecations.handler != nil {
+		c.deprecations.handler(deprecation)
+	}
+}
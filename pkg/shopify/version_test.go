@@ -0,0 +1,73 @@
+package shopify
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/rs/zerolog"
+)
+
+func TestDeprecationReportedOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(apiVersionHeader, "2025-01")
+		if !strings.HasSuffix(r.URL.Path, "/count.json") {
+			w.Header().Set(deprecatedReasonHeader, "https://shopify.dev/changelog")
+		}
+		w.Write([]byte(`{"count":1,"product":{"id":1}}`))
+	}))
+	defer server.Close()
+
+	var deprecations []Deprecation
+	c, err := NewClient(nil,
+		WithCredentials("key", "secret"),
+		WithBaseURLResolver(func(shop string) (*url.URL, error) { return url.Parse(server.URL) }),
+		WithAPIVersion("2026-07"),
+		WithDeprecationHandler(func(deprecation Deprecation) {
+			deprecations = append(deprecations, deprecation)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("NewClient() error = %v", err)
+	}
+
+	var logs bytes.Buffer
+	ctx := zerolog.New(&logs).WithContext(context.Background())
+
+	// the ids are left out of the endpoint, so that every product is
+	// reported as one endpoint
+	for _, id := range []int64{1, 2, 3} {
+		if _, err := c.GetProduct(ctx, "shop.myshopify.com", "token", id, nil); err != nil {
+			t.Fatalf("GetProduct() error = %v", err)
+		}
+	}
+	if _, err := c.CountProduct(ctx, "shop.myshopify.com", "token", nil); err != nil {
+		t.Fatalf("CountProduct() error = %v", err)
+	}
+
+	if got := strings.Count(logs.String(), "deprecated shopify api usage"); got != 2 {
+		t.Errorf("deprecations logged %d times, want once per endpoint:\n%s", got, logs.String())
+	}
+
+	if len(deprecations) != 4 {
+		t.Fatalf("DeprecationHandler called %d times, want 4", len(deprecations))
+	}
+	want := Deprecation{
+		Shop:             "shop.myshopify.com",
+		Method:           http.MethodGet,
+		Endpoint:         "/admin/api/2026-07/products/:id.json",
+		RequestedVersion: "2026-07",
+		ServedVersion:    "2025-01",
+		Reason:           "https://shopify.dev/changelog",
+	}
+	if deprecations[0] != want {
+		t.Errorf("deprecation = %+v, want %+v", deprecations[0], want)
+	}
+	if !deprecations[3].VersionMismatch() || deprecations[3].Reason != "" {
+		t.Errorf("deprecation of the count = %+v, want a version mismatch only", deprecations[3])
+	}
+}
@@ -1,21 +1,29 @@
 package shopify
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"net/url"
 	"time"
-
-	"github.com/rs/zerolog/log"
 )
 
 const webhooksBasePath = "webhooks"
 
+// Headers sent by Shopify along with every webhook delivery.
+const (
+	WebhookHmacHeader       = "X-Shopify-Hmac-Sha256"
+	WebhookTopicHeader      = "X-Shopify-Topic"
+	WebhookShopDomainHeader = "X-Shopify-Shop-Domain"
+	WebhookIdHeader         = "X-Shopify-Webhook-Id"
+)
+
 type WebhookService interface {
-	ListWebhook(shop string, accessToken string, options interface{}) ([]Webhook, error)
-	GetWebhook(shop string, id int64, accessToken string, options interface{}) (*Webhook, error)
-	CreateWebhook(shop string, accessToken string, webhook Webhook) (*Webhook, error)
-	DeleteWebhook(shop string, accessToken string, id int64) error
+	ListWebhook(ctx context.Context, shop string, accessToken string, options interface{}) ([]Webhook, error)
+	ListWebhookWithPagination(ctx context.Context, shop string, accessToken string, options interface{}) ([]Webhook, *Pagination, error)
+	GetWebhook(ctx context.Context, shop string, id int64, accessToken string, options interface{}) (*Webhook, error)
+	CreateWebhook(ctx context.Context, shop string, accessToken string, webhook Webhook) (*Webhook, error)
+	UpdateWebhook(ctx context.Context, shop string, accessToken string, webhook Webhook) (*Webhook, error)
+	DeleteWebhook(ctx context.Context, shop string, accessToken string, id int64) error
 }
 
 type Webhook struct {
@@ -51,45 +59,68 @@ func (r *WebhookResources) ToBytes() []byte {
 
 // WebhookOptions can be used for filtering webhooks on a List request.
 type WebhookOptions struct {
-	Address string `url:"address,omitempty"`
-	Topic   string `url:"topic,omitempty"`
+	ListOptions
+	Address      string     `url:"address,omitempty"`
+	Topic        string     `url:"topic,omitempty"`
+	CreatedAtMin *time.Time `url:"created_at_min,omitempty"`
+	CreatedAtMax *time.Time `url:"created_at_max,omitempty"`
+	UpdatedAtMin *time.Time `url:"updated_at_min,omitempty"`
+	UpdatedAtMax *time.Time `url:"updated_at_max,omitempty"`
 }
 
-func (c *client) ListWebhook(shop, accessToken string, options interface{}) ([]Webhook, error) {
-	requestUrl, err := url.Parse("https://" + shop)
+// ListWebhook returns the webhooks of every page.
+func (c *client) ListWebhook(ctx context.Context, shop, accessToken string, options interface{}) ([]Webhook, error) {
+	return ListAll(func(options interface{}) ([]Webhook, *Pagination, error) {
+		return c.ListWebhookWithPagination(ctx, shop, accessToken, options)
+	}, options)
+}
+
+func (c *client) ListWebhookWithPagination(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	options interface{},
+) ([]Webhook, *Pagination, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s.json", webhooksBasePath))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	requestUrl.Path = fmt.Sprintf("admin/api/%s/%s.json", apiVersion, webhooksBasePath)
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, nil, err
+	}
 
-	req, err := NewRequest("GET", requestUrl, accessToken, nil)
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	result := new(WebhookResources)
-	err = c.SendRequest(req, result)
+	pagination, err := c.SendRequestWithPagination(req, result)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return result.Webhooks, nil
+	return result.Webhooks, pagination, nil
 }
 
 func (c *client) GetWebhook(
+	ctx context.Context,
 	shop string,
 	id int64,
 	accessToken string,
 	options interface{},
 ) (*Webhook, error) {
-	requestUrl, err := url.Parse("https://" + shop)
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", webhooksBasePath, id))
 	if err != nil {
 		return nil, err
 	}
-	requestUrl.Path = fmt.Sprintf("admin/api/%s/%s/%d.json", apiVersion, webhooksBasePath, id)
 
-	req, err := NewRequest("GET", requestUrl, accessToken, nil)
+	if err := setOptions(requestUrl, options); err != nil {
+		return nil, err
+	}
+
+	req, err := c.newRequest(ctx, shop, "GET", requestUrl, accessToken, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -104,18 +135,44 @@ func (c *client) GetWebhook(
 }
 
 func (c *client) CreateWebhook(
+	ctx context.Context,
+	shop string,
+	accessToken string,
+	webhook Webhook,
+) (*Webhook, error) {
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s.json", webhooksBasePath))
+	if err != nil {
+		return nil, err
+	}
+
+	request := WebhookResource{Webhook: &webhook}
+	req, err := c.newRequest(ctx, shop, "POST", requestUrl, accessToken, request)
+	if err != nil {
+		return nil, err
+	}
+
+	result := new(WebhookResource)
+	err = c.SendRequest(req, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Webhook, nil
+}
+
+func (c *client) UpdateWebhook(
+	ctx context.Context,
 	shop string,
 	accessToken string,
 	webhook Webhook,
 ) (*Webhook, error) {
-	requestUrl, err := url.Parse("https://" + shop)
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", webhooksBasePath, webhook.ID))
 	if err != nil {
 		return nil, err
 	}
-	requestUrl.Path = fmt.Sprintf("admin/api/%s/%s.json", apiVersion, webhooksBasePath)
 
 	request := WebhookResource{Webhook: &webhook}
-	req, err := NewRequest("POST", requestUrl, accessToken, request)
+	req, err := c.newRequest(ctx, shop, "PUT", requestUrl, accessToken, request)
 	if err != nil {
 		return nil, err
 	}
@@ -130,19 +187,19 @@ func (c *client) CreateWebhook(
 }
 
 func (c *client) DeleteWebhook(
+	ctx context.Context,
 	shop string,
 	accessToken string,
 	id int64,
 ) error {
-	requestUrl, err := url.Parse("https://" + shop)
+	requestUrl, err := c.createUrl(shop, fmt.Sprintf("%s/%d.json", webhooksBasePath, id))
 	if err != nil {
 		return err
 	}
-	requestUrl.Path = fmt.Sprintf("admin/api/%s/%s/%d.json", apiVersion, webhooksBasePath, id)
 
-	log.Info().Str("url", requestUrl.String()).Msg("delete url")
+	c.logger.Info().Str("url", requestUrl.String()).Msg("delete url")
 
-	req, err := NewRequest("DELETE", requestUrl, accessToken, nil)
+	req, err := c.newRequest(ctx, shop, "DELETE", requestUrl, accessToken, nil)
 	if err != nil {
 		return err
 	}
@@ -154,3 +211,36 @@ func (c *client) DeleteWebhook(
 
 	return nil
 }
+
+// ComplianceCustomer identifies the customer of a privacy webhook.
+type ComplianceCustomer struct {
+	ID    int64  `json:"id"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// CustomersDataRequestPayload is the body of the customers/data_request
+// webhook.
+type CustomersDataRequestPayload struct {
+	ShopID          int64              `json:"shop_id"`
+	ShopDomain      string             `json:"shop_domain"`
+	OrdersRequested []int64            `json:"orders_requested"`
+	Customer        ComplianceCustomer `json:"customer"`
+	DataRequest     struct {
+		ID int64 `json:"id"`
+	} `json:"data_request"`
+}
+
+// CustomersRedactPayload is the body of the customers/redact webhook.
+type CustomersRedactPayload struct {
+	ShopID         int64              `json:"shop_id"`
+	ShopDomain     string             `json:"shop_domain"`
+	Customer       ComplianceCustomer `json:"customer"`
+	OrdersToRedact []int64            `json:"orders_to_redact"`
+}
+
+// ShopRedactPayload is the body of the shop/redact webhook.
+type ShopRedactPayload struct {
+	ShopID     int64  `json:"shop_id"`
+	ShopDomain string `json:"shop_domain"`
+}